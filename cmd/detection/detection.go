@@ -1,216 +1,157 @@
 package main
 
 import (
-	"encoding/binary"
+	"bufio"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	//"math"
+	"io"
 	"os"
 	"os/signal"
-	"strconv"
 	"time"
 
-	"github.com/gen2brain/malgo"
-	"github.com/rebay1982/gdsp/fft"
-	"github.com/rebay1982/gdsp/filters"
-	"github.com/rebay1982/gdsp/windowing"
+	"github.com/rebay1982/gmorse/internal/audio"
 	"github.com/rebay1982/gmorse/internal/decode"
+	"github.com/rebay1982/gmorse/internal/detect"
 )
 
-// Setup device to validate capture.
+// Rate the audio is processed at, whatever it comes in at, and audio handed over at once.
 const (
 	sampleRate   = 8000
 	periodSizeMS = 10
-	blockSize    = 128
 )
 
-// Avoid recreating these every time the onReceiveFrames function is called.
-var samples []float64 = make([]float64, blockSize)
-var frequencies []float64 = []float64{500, 550, 600, 650, 700, 750, 800, 850, 900, 950}
-var mags []float64 = make([]float64, len(frequencies))
-var detectionCh = make(chan bool, 1)
-
-func OnReceiveFrames(_, iSamples []byte, sampleCount uint32) {
-	// Normalize
-	for i := range sampleCount {
-		samples[i] = fft.NormalizePCM16(int16(binary.LittleEndian.Uint16(iSamples[i<<1 : (i+1)<<1])))
-	}
-
-	// Window (reduces spectral leakage)
-	// Only apply it to the samples, not the padding.
-	windowing.Hann(samples[:sampleCount])
-
-	// Retrieve Goertzel calculation for all frequencies.
-	for i, f := range frequencies {
-		goertzel := filters.Goertzel(sampleRate, f, samples)
-		mags[i] = fft.ComputeMagnitude(goertzel) * 2 // Compensate for the Hanning window
-	}
+// run Detect and decode the audio of the source, printing the text as it comes, until the audio runs out or the context
+// is done.
+func run(ctx context.Context, source audio.Source, detector *detect.Detector, decoder *decode.MorseDecoder,
+	w io.Writer) error {
+	decodeIn := make(chan decode.Detection, 16)
+	decodeOut := make(chan decode.Event)
+	decoded := make(chan error)
+	go func() {
+		decoded <- decoder.Run(ctx, decodeIn, decodeOut)
+	}()
 
-	// Display detection
-	//maxMag := 0.0
-	detection := false
-	for i := range frequencies {
-		//if mags[i] > maxMag {
-		//	maxMag = mags[i]
-		//}
-		if mags[i] > 1.0 {
-			detection = true
+	printed := make(chan struct{})
+	go func() {
+		defer close(printed)
+		for e := range decodeOut {
+			fmt.Fprint(w, e)
 		}
-	}
-
-	//fmt.Println(maxMag)
-	detectionCh <- detection
-}
+	}()
 
-var prevDetection = false
-var onStart time.Time
-var onEnd time.Time
-var onLength time.Duration
-var offStart time.Time
-var offEnd time.Time
-var offLength time.Duration
-
-func HandleDetection(detectionCh <-chan bool, decodeIn chan<- decode.Detection, done <-chan struct{}) {
-	for {
-		select {
-		case morseDetected := <-detectionCh:
-
-			// Edge Detection
-			if morseDetected != prevDetection {
-				// Rising edge
-				if morseDetected {
-					offEnd = time.Now()
-					onStart = time.Now()
-
-					offLength = offEnd.Sub(offStart)
-
-					// Falling edge
-				} else {
-					onEnd = time.Now()
-					offStart = time.Now()
-
-					onLength = onEnd.Sub(onStart)
-				}
-
-				detection := decode.Detection{
-					State: prevDetection,
-				}
-				if prevDetection {
-					detection.Duration = onLength
-				} else {
-					detection.Duration = offLength
-				}
-				decodeIn <- detection
-
-				prevDetection = morseDetected
-			} else {
-				// Time out after a second or two of silence.
-				if !morseDetected {
-					if time.Now().Sub(offStart).Seconds() > 2 {
-						offEnd = time.Now()
-
-						detection := decode.Detection{
-							State:    prevDetection,
-							Duration: offEnd.Sub(offStart),
-						}
-						offStart = time.Now()
-
-						decodeIn <- detection
-					}
-				}
-			}
-
-		case <-done:
-			fmt.Println("Exiting detection routine")
-			return
-		}
-	}
+	// The detector closes decodeIn once done, the decoder then flushes and closes decodeOut.
+	err := detector.Run(ctx, source, decodeIn)
+	err = errors.Join(err, <-decoded)
+	<-printed
+	return err
 }
 
 func main() {
-	// Setup malgo
-	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
-	if err != nil {
-		fmt.Println("Did not work...")
-	}
-	defer func() {
-		_ = ctx.Uninit()
-		ctx.Free()
-	}()
-
-	// List capture devices
-	fmt.Println("")
-	fmt.Println("Capture devices:")
-	cDevs, err := ctx.Devices(malgo.Capture)
-	for i, cDev := range cDevs {
-		fmt.Printf("%d: %s, default: %d\n", i, cDev.Name(), cDev.IsDefault)
-	}
+	file := flag.String("file", "", "WAV file to decode instead of capturing from a device")
+	raw := flag.Bool("raw", false, "decode raw PCM from stdin instead of capturing from a device")
+	format := flag.String("format", "s16", "format of the raw PCM: u8, s16, s24, s32 or f32 (little-endian)")
+	rate := flag.Int("rate", sampleRate, "sample rate of the raw PCM or to capture at")
+	channels := flag.Int("channels", 1, "channels of the raw PCM")
+	channel := flag.Int("channel", 0, "channel of the WAV file or raw PCM to decode, from 0")
+	iq := flag.Bool("iq", false, "decode complex baseband, I and Q on the first two channels, as SDRs produce")
+	offset := flag.Float64("offset", 0, "Hz of the signal from the center of the IQ baseband, negative below it")
+	bandwidth := flag.Float64("bandwidth", 500, "Hz around the signal let through from the IQ baseband")
+	flag.Parse()
+
+	var source audio.Source
+	config := detect.Config{SampleRate: sampleRate, Channel: *channel, Interpolate: true, Adaptive: true, Track: true}
+	live := false
+	switch {
+	case *file != "":
+		f, err := os.Open(*file)
+		if err != nil {
+			fmt.Println("Could not open the file:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
 
-	fmt.Println("-- Select input device: ")
-	var strDevId string
-	fmt.Scanln(&strDevId)
+		wav, err := audio.NewWAV(bufio.NewReader(f), audio.WAVConfig{Period: periodSizeMS * time.Millisecond})
+		if err != nil {
+			fmt.Println("Could not read the file:", err)
+			os.Exit(1)
+		}
+		source = wav
+		*channels = wav.Channels()
+
+	case *raw:
+		pcmFormat, err := audio.ParseFormat(*format)
+		if err != nil {
+			fmt.Println("Bad format:", err)
+			os.Exit(2)
+		}
+		if *rate <= 0 || *channels <= 0 {
+			fmt.Printf("Bad layout, expecting a positive rate and number of channels, got %dHz and %d\n", *rate, *channels)
+			os.Exit(2)
+		}
+		source = audio.NewRaw(bufio.NewReader(os.Stdin), audio.RawConfig{
+			Format:     pcmFormat,
+			Channels:   *channels,
+			SampleRate: *rate,
+			Period:     periodSizeMS * time.Millisecond,
+		})
+
+	default:
+		device, err := audio.SelectDevice(os.Stdin, os.Stdout)
+		if err != nil {
+			fmt.Println("Could not select the device:", err)
+			os.Exit(1)
+		}
 
-	selectedDevId, err := strconv.Atoi(strDevId)
-	if err != nil {
-		fmt.Println("Bad user input, exiting...")
-		os.Exit(1)
-	}
-	if selectedDevId < 0 || selectedDevId >= len(cDevs) {
-		fmt.Printf("Bad user input, expecting value between 0 and %d, got %d\n", len(cDevs), selectedDevId)
-		os.Exit(1)
+		fmt.Print("Configuring device and detector... ")
+		*channels = 1
+		if *iq {
+			*channels = 2
+		}
+		source = audio.NewDevice(audio.DeviceConfig{
+			Device:     device,
+			SampleRate: *rate,
+			Channels:   *channels,
+			Period:     periodSizeMS * time.Millisecond,
+		})
+		live = true
+		fmt.Println("Done")
+		fmt.Println("Starting capture...")
 	}
-
-	fmt.Print("Configuring device and callback routine... ")
-	deviceConfig := malgo.DefaultDeviceConfig(malgo.Capture)
-	selInfo := cDevs[1]
-	deviceId := selInfo.ID
-	deviceConfig.Capture.DeviceID = deviceId.Pointer()
-	deviceConfig.Capture.Format = malgo.FormatS16
-	deviceConfig.PeriodSizeInMilliseconds = periodSizeMS
-	deviceConfig.Capture.Channels = 1
-	deviceConfig.SampleRate = sampleRate
-	deviceConfig.Alsa.NoMMap = 1
-	captureCallbacks := malgo.DeviceCallbacks{
-		Data: OnReceiveFrames,
+	if *iq {
+		// The signal is taken out of both channels into mono audio.
+		if *channels != 2 {
+			fmt.Printf("Bad layout, expecting I and Q on 2 channels, got %d\n", *channels)
+			os.Exit(2)
+		}
+		source = audio.NewIQ(source, audio.IQConfig{Offset: *offset, Bandwidth: *bandwidth, SampleRate: sampleRate})
+		config.Channel = 0
+	} else if *channel < 0 || *channel >= *channels {
+		fmt.Printf("Bad channel, expecting a value between 0 and %d, got %d\n", *channels-1, *channel)
+		os.Exit(2)
 	}
-	device, err := malgo.InitDevice(ctx.Context, deviceConfig, captureCallbacks)
-	defer device.Uninit()
-
-	// Initialize detection handling.
-	done := make(chan struct{}, 1)
-	decodeIn := make(chan decode.Detection)
-	go HandleDetection(detectionCh, decodeIn, done)
-	fmt.Println("Done")
 
-	fmt.Print("Initializing morse decoder... ")
-	decodeConfig := decode.DecoderConfig{
+	// Blocks already at the processing rate go through as they are.
+	source = audio.NewResampled(source, sampleRate)
+	detector := detect.NewDetector(config)
+	decoder := decode.NewDecoder(decode.DecoderConfig{
 		Wpm:      25,
 		Tolerace: 0.4,
-	}
-	decodeOut := make(chan string)
-	decoder := decode.NewMorseDecoder(decodeIn, decodeOut, done, decodeConfig)
-	decoder.StartDecode()
-	fmt.Println("Done")
-
-	fmt.Println("Starting capture...")
-	device.Start()
+		Adaptive: true,
+	})
 
-	go func() {
-		for {
-			select {
-			//case <-decodeOut:
-			case msg := <-decodeOut:
-				fmt.Print(msg)
-			case <-done:
-				return
-			}
-		}
-	}()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
-	<-sig
-
-	close(done)
+	if err := run(ctx, source, detector, decoder, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Println("\nDecoding failed:", err)
+		os.Exit(1)
+	}
 
-	fmt.Println("\nExiting...")
+	if live {
+		fmt.Println("\nExiting...")
+	} else {
+		fmt.Println()
+	}
 }
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/audio"
+	"github.com/rebay1982/gmorse/internal/decode"
+	"github.com/rebay1982/gmorse/internal/detect"
+	"github.com/rebay1982/gmorse/internal/synth"
+)
+
+// Test_EndToEnd Play synthesized CW through the detector and decoder, as the sound card would but
+// as fast as it goes. Timing comes from the samples, not from when they are handed over.
+func Test_EndToEnd(t *testing.T) {
+	detections, err := decode.NewEncoder(decode.EncoderConfig{Wpm: 20}).Encode("TEST")
+	if err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+
+	synthesizer := synth.NewSynthesizer(synth.Config{SampleRate: sampleRate, Frequency: 700})
+	samples := append(synthesizer.Silence(100*time.Millisecond), synthesizer.Render(detections)...)
+	source := synth.NewSource(samples, synth.SourceConfig{
+		SampleRate: sampleRate,
+		Period:     periodSizeMS * time.Millisecond,
+	})
+
+	detector := detect.NewDetector(detect.Config{SampleRate: sampleRate, Interpolate: true, Adaptive: true, Track: true})
+	decoder := decode.NewDecoder(decode.DecoderConfig{Wpm: 20, Tolerace: 0.4})
+
+	// The last character is only complete once the input is over.
+	output := strings.Builder{}
+	if err := run(context.Background(), source, detector, decoder, &output); err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+	if text := output.String(); text != "TEST" {
+		t.Errorf("expecting [TEST], got [%s]", text)
+	}
+}
+
+// Test_DecodeWAV Decode recordings as fast as they are read, at the processing rate whatever their rate, printing the
+// same text as when capturing.
+func Test_DecodeWAV(t *testing.T) {
+	detections, err := decode.NewEncoder(decode.EncoderConfig{Wpm: 20}).Encode("CQ DE W1AW")
+	if err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+
+	for _, rate := range []int{8000, 44100, 48000} {
+		synthesizer := synth.NewSynthesizer(synth.Config{SampleRate: rate, Frequency: 700})
+		samples := append(synthesizer.Silence(500*time.Millisecond), synthesizer.Render(detections)...)
+		samples = synth.NewChannel(synth.ChannelConfig{SampleRate: rate, SNR: 20, Noise: true, Seed: 1}).Apply(samples)
+
+		file := bytes.Buffer{}
+		if err := synth.WriteWAV(&file, rate, samples); err != nil {
+			t.Fatalf("expecting no error, got %v", err)
+		}
+		wav, err := audio.NewWAV(&file, audio.WAVConfig{Period: periodSizeMS * time.Millisecond})
+		if err != nil {
+			t.Fatalf("expecting no error, got %v", err)
+		}
+
+		detector := detect.NewDetector(detect.Config{SampleRate: sampleRate, Interpolate: true, Adaptive: true, Track: true})
+		decoder := decode.NewDecoder(decode.DecoderConfig{Wpm: 20, Tolerace: 0.4})
+
+		output := strings.Builder{}
+		if err := run(context.Background(), audio.NewResampled(wav, sampleRate), detector, decoder, &output); err != nil {
+			t.Fatalf("expecting no error at %dHz, got %v", rate, err)
+		}
+		if text := strings.TrimSpace(output.String()); text != "CQ DE W1AW" {
+			t.Errorf("expecting [CQ DE W1AW] at %dHz, got [%s]", rate, text)
+		}
+	}
+}
+
+// Test_DecodeIQ Take one station out of IQ from an SDR, with another one on its image, the other side of the center,
+// and another close by.
+func Test_DecodeIQ(t *testing.T) {
+	const rate = 48000
+	stations := []struct {
+		text      string
+		frequency float64
+	}{
+		{text: "TEST", frequency: 10000},
+		{text: "CQ CQ", frequency: -10000},
+		{text: "DE W1AW", frequency: 11000},
+	}
+
+	// Keyed complex tones, I and Q on top of each other, in noise.
+	levels := make([][]float64, len(stations))
+	length := 0
+	for s, station := range stations {
+		detections, err := decode.NewEncoder(decode.EncoderConfig{Wpm: 20}).Encode(station.text)
+		if err != nil {
+			t.Fatalf("expecting no error, got %v", err)
+		}
+		levels[s] = envelope(detections, 500*time.Millisecond, rate)
+		length = max(length, len(levels[s]))
+	}
+
+	random := rand.New(rand.NewPCG(1, 1))
+	i, q := make([]float64, length), make([]float64, length)
+	for n := range i {
+		i[n], q[n] = 0.02*random.NormFloat64(), 0.02*random.NormFloat64()
+	}
+	for s, station := range stations {
+		for n, level := range levels[s] {
+			phase := 2 * math.Pi * station.frequency * float64(n) / rate
+			i[n] += 0.2 * level * math.Cos(phase)
+			q[n] += 0.2 * level * math.Sin(phase)
+		}
+	}
+
+	pcm := []byte{}
+	for n := range i {
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(int16(i[n]*math.MaxInt16)))
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(int16(q[n]*math.MaxInt16)))
+	}
+
+	for _, station := range stations {
+		raw := audio.NewRaw(bytes.NewReader(pcm), audio.RawConfig{
+			Format:     audio.FormatS16,
+			Channels:   2,
+			SampleRate: rate,
+			Period:     periodSizeMS * time.Millisecond,
+		})
+		source := audio.NewIQ(raw, audio.IQConfig{Offset: station.frequency, SampleRate: sampleRate})
+
+		detector := detect.NewDetector(detect.Config{SampleRate: sampleRate, Interpolate: true, Adaptive: true, Track: true})
+		decoder := decode.NewDecoder(decode.DecoderConfig{Wpm: 20, Tolerace: 0.4})
+
+		output := strings.Builder{}
+		if err := run(context.Background(), source, detector, decoder, &output); err != nil {
+			t.Fatalf("expecting no error at %gHz, got %v", station.frequency, err)
+		}
+		if text := strings.TrimSpace(output.String()); text != station.text {
+			t.Errorf("expecting [%s] at %gHz, got [%s]", station.text, station.frequency, text)
+		}
+	}
+}
+
+// envelope Level of the keyed tone, from 0 to 1, sample after sample from a delay on. Edges ramp over 5ms to keep the
+// clicks out of the stations close by.
+func envelope(detections []decode.Detection, delay time.Duration, sampleRate int) []float64 {
+	keyed := make([]float64, int(delay.Seconds()*float64(sampleRate)))
+	for _, d := range detections {
+		level := 0.0
+		if d.State {
+			level = 1
+		}
+		for range int(d.Duration.Seconds() * float64(sampleRate)) {
+			keyed = append(keyed, level)
+		}
+	}
+
+	ramp := sampleRate / 200
+	levels := make([]float64, len(keyed))
+	sum := 0.0
+	for n, level := range keyed {
+		sum += level
+		if n >= ramp {
+			sum -= keyed[n-ramp]
+		}
+		levels[n] = sum / float64(ramp)
+	}
+	return levels
+}
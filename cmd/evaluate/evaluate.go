@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/audio"
+	"github.com/rebay1982/gmorse/internal/decode"
+	"github.com/rebay1982/gmorse/internal/detect"
+	"github.com/rebay1982/gmorse/internal/eval"
+	"github.com/rebay1982/gmorse/internal/synth"
+)
+
+// Rate the audio is processed at, whatever the recordings are at, and audio handed over to the detector at once, the
+// same as cmd/detection.
+const (
+	sampleRate   = 8000
+	periodSizeMS = 10
+)
+
+// Corpus generated with -generate.
+var (
+	generateTexts = []string{
+		"CQ CQ DE W1AW W1AW K",
+		"TNX FER CALL UR RST 579 579 NAME JOHN",
+		"QTH BOSTON MA RIG IS 100 WATTS ANT DIPOLE",
+		"WX SUNNY TEMP 20C HW CPY",
+		"73 ES GL DE VE2XYZ SK",
+	}
+	generateWpms = []int{15, 20, 25, 30}
+	generateSNRs = []float64{0, 6, 12, 20}
+)
+
+const generateSampleRate = 8000
+
+func main() {
+	dir := flag.String("dir", "", "directory of WAV files, each with a .txt transcript and an optional .json of labels")
+	wpm := flag.Int("wpm", 25, "initial decoding speed")
+	tolerance := flag.Float64("tolerance", 0.4, "timing tolerance")
+	adaptive := flag.Bool("adaptive", true, "track the sender speed")
+	viterbi := flag.Bool("viterbi", false, "decode with the Viterbi mode")
+	diff := flag.Bool("diff", false, "show what was decoded against the transcript for files with errors")
+	generate := flag.Bool("generate", false, "generate a synthetic corpus in the directory instead of evaluating")
+	seed := flag.Uint64("seed", 1, "seed for the synthetic corpus")
+	threshold := flag.Float64("threshold", 0, "fixed tone detection threshold, adaptive when 0")
+	track := flag.Bool("track", true, "find the carrier and listen on it alone")
+	flag.Parse()
+
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *generate {
+		if err := generateCorpus(*dir, *seed); err != nil {
+			fmt.Println("Could not generate the corpus:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	config := decode.DecoderConfig{
+		Wpm:      *wpm,
+		Tolerace: *tolerance,
+		Adaptive: *adaptive,
+	}
+	if *viterbi {
+		config.Mode = decode.ModeViterbi
+	}
+	if err := config.Validate(); err != nil {
+		fmt.Println("Bad decoding settings:", err)
+		os.Exit(2)
+	}
+
+	results, err := evaluate(*dir, config, detect.Config{Threshold: *threshold, Adaptive: *threshold == 0, Track: *track})
+	if err != nil {
+		fmt.Println("Could not evaluate:", err)
+		os.Exit(1)
+	}
+	report(results, *diff)
+}
+
+// evaluate Decode every WAV file of the directory and score it against its transcript, detecting the tone as
+// configured. The files are resampled to the processing rate.
+func evaluate(dir string, config decode.DecoderConfig, detection detect.Config) ([]eval.Result, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.wav"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no WAV files in %s", dir)
+	}
+
+	results := []eval.Result{}
+	for _, file := range files {
+		base := strings.TrimSuffix(file, filepath.Ext(file))
+
+		transcript, err := os.ReadFile(base + ".txt")
+		if err != nil {
+			return nil, err
+		}
+
+		labels := eval.Labels{}
+		if data, err := os.ReadFile(base + ".json"); err == nil {
+			if err := json.Unmarshal(data, &labels); err != nil {
+				return nil, fmt.Errorf("%s.json: %w", base, err)
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		decoded, err := decodeFile(file, config, detection)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		results = append(results, eval.Evaluate(filepath.Base(base), string(transcript), decoded, labels))
+	}
+
+	return results, nil
+}
+
+// decodeFile Run the recording through tone detection and decoding.
+func decodeFile(file string, config decode.DecoderConfig, detection detect.Config) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	wav, err := audio.NewWAV(bufio.NewReader(f), audio.WAVConfig{Period: periodSizeMS * time.Millisecond})
+	if err != nil {
+		return "", err
+	}
+
+	detections, err := detectTone(audio.NewResampled(wav, sampleRate), detection)
+	if err != nil {
+		return "", err
+	}
+
+	decoder := decode.NewDecoder(config)
+	events := decoder.Decode(detections)
+	events = append(events, decoder.Flush()...)
+
+	return decode.Text(events), nil
+}
+
+// detectTone Find the tone in the audio of the source, period by period as the sound card would hand them over.
+func detectTone(source audio.Source, config detect.Config) ([]decode.Detection, error) {
+	config.SampleRate = sampleRate
+	config.Interpolate = true
+	detector := detect.NewDetector(config)
+
+	detections := []decode.Detection{}
+	err := source.Run(context.Background(), func(block audio.Block) {
+		detections = append(detections, detector.ProcessBlock(block)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := detector.Flush(); ok {
+		detections = append(detections, d)
+	}
+
+	return detections, nil
+}
+
+// report Print the score of each file, then overall and broken down by SNR and speed.
+func report(results []eval.Result, diff bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tWPM\tSNR\tCER\tWER")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.Name, label(r.Labels.Wpm), label(r.Labels.SNR), rate(r.CER),
+			rate(r.WER))
+	}
+	w.Flush()
+
+	if diff {
+		fmt.Println()
+		for _, r := range results {
+			if r.CER.Errors() > 0 {
+				fmt.Printf("%s\n  expected: %s\n  decoded:  %s\n", r.Name, eval.Normalize(r.Reference),
+					eval.Diff(r.Reference, r.Decoded))
+			}
+		}
+	}
+
+	total := eval.Total(results)
+	fmt.Printf("\nOverall: %d files, CER %s (%d/%d), WER %s (%d/%d)\n", total.Files, rate(total.CER),
+		total.CER.Errors(), total.CER.Length, rate(total.WER), total.WER.Errors(), total.WER.Length)
+
+	breakdown("SNR (dB)", eval.Breakdown(results, eval.BySNR))
+	breakdown("WPM", eval.Breakdown(results, eval.ByWpm))
+}
+
+func breakdown(name string, groups []eval.Group) {
+	if len(groups) == 0 {
+		return
+	}
+
+	fmt.Printf("\nBy %s:\n", name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tFILES\tCER\tWER\n", strings.ToUpper(name))
+	for _, g := range groups {
+		fmt.Fprintf(w, "%g\t%d\t%s\t%s\n", g.Value, g.Files, rate(g.CER), rate(g.WER))
+	}
+	w.Flush()
+}
+
+func label(value *float64) string {
+	if value == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%g", *value)
+}
+
+func rate(s eval.Score) string {
+	return fmt.Sprintf("%.1f%%", 100*s.Rate())
+}
+
+// generateCorpus Write every text at every speed, clean and at every SNR, as labelled recordings.
+func generateCorpus(dir string, seed uint64) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for t, text := range generateTexts {
+		for _, wpm := range generateWpms {
+			name := fmt.Sprintf("text%d-%dwpm", t+1, wpm)
+			if err := generateRecording(filepath.Join(dir, name+"-clean"), text, wpm, nil, seed); err != nil {
+				return err
+			}
+
+			for _, snr := range generateSNRs {
+				seed++
+				base := filepath.Join(dir, fmt.Sprintf("%s-%gdb", name, snr))
+				if err := generateRecording(base, text, wpm, &snr, seed); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// generateRecording Write the text sent at the speed, with noise for the SNR if given, along with its transcript and
+// labels.
+func generateRecording(base, text string, wpm int, snr *float64, seed uint64) error {
+	detections, err := decode.NewEncoder(decode.EncoderConfig{Wpm: wpm, Jitter: 0.05, Seed: seed}).Encode(text)
+	if err != nil {
+		return err
+	}
+
+	synthesizer := synth.NewSynthesizer(synth.Config{SampleRate: generateSampleRate, Frequency: 700})
+	samples := synthesizer.Silence(500 * time.Millisecond)
+	samples = append(samples, synthesizer.Render(detections)...)
+	samples = append(samples, synthesizer.Silence(time.Second)...)
+
+	if snr != nil {
+		channel := synth.NewChannel(synth.ChannelConfig{SampleRate: generateSampleRate, SNR: *snr, Noise: true, Seed: seed})
+		samples = channel.Apply(samples)
+	}
+
+	f, err := os.Create(base + ".wav")
+	if err != nil {
+		return err
+	}
+	if err := synth.WriteWAV(f, generateSampleRate, samples); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(base+".txt", []byte(text+"\n"), 0o644); err != nil {
+		return err
+	}
+
+	speed := float64(wpm)
+	labels, err := json.Marshal(eval.Labels{Wpm: &speed, SNR: snr})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(base+".json", labels, 0o644)
+}
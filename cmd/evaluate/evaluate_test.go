@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rebay1982/gmorse/internal/decode"
+	"github.com/rebay1982/gmorse/internal/detect"
+)
+
+func Test_Evaluate(t *testing.T) {
+	dir := t.TempDir()
+	snr := 30.0
+	if err := generateRecording(filepath.Join(dir, "clean"), "CQ DE W1AW", 20, nil, 1); err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+	if err := generateRecording(filepath.Join(dir, "noisy"), "CQ DE W1AW", 20, &snr, 1); err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+
+	results, err := evaluate(dir, decode.DecoderConfig{Wpm: 20, Tolerace: 0.4}, detect.Config{Adaptive: true, Track: true})
+	if err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expecting 2 results, got %d", len(results))
+	}
+
+	clean := results[0]
+	if clean.Name != "clean" || clean.CER.Errors() != 0 || clean.Labels.Wpm == nil || *clean.Labels.Wpm != 20 {
+		t.Errorf("expecting the clean recording decoded without errors, got %+v", clean)
+	}
+	if noisy := results[1]; noisy.Labels.SNR == nil || *noisy.Labels.SNR != snr || noisy.CER.Errors() != 0 {
+		t.Errorf("expecting the noisy recording decoded without errors and labelled with its SNR, got %+v", noisy)
+	}
+}
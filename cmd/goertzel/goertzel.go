@@ -1,18 +1,18 @@
 package main
 
 import (
-	"encoding/binary"
+	"context"
+	"errors"
 	"fmt"
 	//"math"
 	"os"
 	"os/signal"
-	"strconv"
 	"time"
 
-	"github.com/gen2brain/malgo"
 	"github.com/rebay1982/gdsp/fft"
 	"github.com/rebay1982/gdsp/filters"
 	"github.com/rebay1982/gdsp/windowing"
+	"github.com/rebay1982/gmorse/internal/audio"
 )
 
 // Setup device to validate capture.
@@ -23,17 +23,18 @@ const (
 )
 
 // Avoid recreating these every time the onReceiveFrames function is called.
+var pcm []float64
 var samples []float64 = make([]float64, blockSize)
 var frequencies []float64 = []float64{500, 550, 600, 650, 700, 750, 800, 850, 900, 950}
 var mags []float64 = make([]float64, len(frequencies))
 
-func OnReceiveFrames(_, iSamples []byte, sampleCount uint32) {
+func OnReceiveFrames(block audio.Block) {
 	startTime := time.Now()
 
-	// Normalize
-	for i := range sampleCount {
-		samples[i] = fft.NormalizePCM16(int16(binary.LittleEndian.Uint16(iSamples[i<<1 : (i+1)<<1])))
-	}
+	// Normalize, zero padding up to the block size.
+	pcm = block.Samples(0, pcm[:0])
+	sampleCount := copy(samples, pcm)
+	clear(samples[sampleCount:])
 
 	// Window (reduces spectral leakage)
 	// Only apply it to the samples, not the padding.
@@ -61,62 +62,22 @@ func OnReceiveFrames(_, iSamples []byte, sampleCount uint32) {
 }
 
 func main() {
-	// Setup malgo
-	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	device, err := audio.SelectDevice(os.Stdin, os.Stdout)
 	if err != nil {
-		fmt.Println("Did not work...")
-	}
-	defer func() {
-		_ = ctx.Uninit()
-		ctx.Free()
-	}()
-
-	// List capture devices
-	fmt.Println("")
-	fmt.Println("Capture devices:")
-	cDevs, err := ctx.Devices(malgo.Capture)
-	for i, cDev := range cDevs {
-		fmt.Printf("%d: %s, default: %d\n", i, cDev.Name(), cDev.IsDefault)
-	}
-
-	fmt.Println("-- Select input device: ")
-	var strDevId string
-	fmt.Scanln(&strDevId)
-
-	selectedDevId, err := strconv.Atoi(strDevId)
-	if err != nil {
-		fmt.Println("Bad user input, exiting...")
-		os.Exit(1)
-	}
-	if selectedDevId < 0 || selectedDevId >= len(cDevs) {
-		fmt.Printf("Bad user input, expecting value between 0 and %d, got %d\n", len(cDevs), selectedDevId)
+		fmt.Println("Could not select the device:", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("\n\n--- Initializing capture on default device ---")
-	deviceConfig := malgo.DefaultDeviceConfig(malgo.Capture)
-	selInfo := cDevs[1]
-	deviceId := selInfo.ID
-
-	deviceConfig.Capture.DeviceID = deviceId.Pointer()
-	deviceConfig.Capture.Format = malgo.FormatS16
-	deviceConfig.Capture.Channels = 1
-	deviceConfig.SampleRate = sampleRate
-	deviceConfig.Alsa.NoMMap = 1
-
-	captureCallbacks := malgo.DeviceCallbacks{
-		Data: OnReceiveFrames,
-	}
+	source := audio.NewDevice(audio.DeviceConfig{Device: device, SampleRate: sampleRate})
 
-	device, err := malgo.InitDevice(ctx.Context, deviceConfig, captureCallbacks)
-	defer device.Uninit()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
 	fmt.Println("\n\n--- Initializing capture on default device ---")
-	device.Start()
-
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
-	<-sig
+	if err := source.Run(ctx, OnReceiveFrames); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Println("\nCapture failed:", err)
+		os.Exit(1)
+	}
 
 	fmt.Println("\nExiting...")
 }
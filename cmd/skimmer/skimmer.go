@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/audio"
+	"github.com/rebay1982/gmorse/internal/decode"
+	"github.com/rebay1982/gmorse/internal/skimmer"
+)
+
+// Rate the audio is processed at, whatever the recording is at, and audio handed over to the skimmer at once, the same
+// as cmd/detection.
+const (
+	sampleRate   = 8000
+	periodSizeMS = 10
+)
+
+func main() {
+	file := flag.String("file", "", "WAV file to skim")
+	minFrequency := flag.Float64("min", 200, "lowest carrier frequency")
+	maxFrequency := flag.Float64("max", 3400, "highest carrier frequency")
+	wpm := flag.Int("wpm", 25, "initial decoding speed")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config := skimmer.Config{
+		MinFrequency: *minFrequency,
+		MaxFrequency: *maxFrequency,
+		Decoder:      decode.DecoderConfig{Wpm: *wpm, Tolerace: 0.4, Adaptive: true},
+	}
+	if err := config.Decoder.Validate(); err != nil {
+		fmt.Println("Bad decoding settings:", err)
+		os.Exit(2)
+	}
+	if err := skimFile(*file, config, os.Stdout); err != nil {
+		fmt.Println("Could not skim:", err)
+		os.Exit(1)
+	}
+}
+
+// skimFile Run the recording through the skimmer, period by period as the sound card would hand them over, printing
+// every word decoded along with the channel it was heard on. The first channel of the recording is skimmed.
+func skimFile(file string, config skimmer.Config, w io.Writer) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	wav, err := audio.NewWAV(bufio.NewReader(f), audio.WAVConfig{Period: periodSizeMS * time.Millisecond})
+	if err != nil {
+		return err
+	}
+
+	config.SampleRate = sampleRate
+	s := skimmer.NewSkimmer(config)
+	p := newPrinter(w)
+
+	var samples []float64
+	err = audio.NewResampled(wav, sampleRate).Run(context.Background(), func(block audio.Block) {
+		samples = block.Samples(0, samples[:0])
+		p.print(s.Process(samples))
+	})
+	if err != nil {
+		return err
+	}
+	p.print(s.Flush())
+
+	return nil
+}
+
+// printer Puts the characters of each channel together, printing a line per word.
+type printer struct {
+	w     io.Writer
+	words map[int]*word
+}
+
+// word Word being decoded on a channel.
+type word struct {
+	event skimmer.Event
+	text  strings.Builder
+}
+
+func newPrinter(w io.Writer) *printer {
+	return &printer{w: w, words: map[int]*word{}}
+}
+
+// print Add the events to the words of their channel, printing those they end.
+func (p *printer) print(events []skimmer.Event) {
+	for _, e := range events {
+		current := p.words[e.Channel]
+		switch e.Kind {
+		case decode.EventWordBreak, decode.EventEndOfTransmission:
+			if current != nil {
+				fmt.Fprintf(p.w, "%8.2fs %3d %7.1fHz %3.0fwpm  %s\n", current.event.Start.Seconds(), e.Channel,
+					e.Frequency, e.Wpm, current.text.String())
+				delete(p.words, e.Channel)
+			}
+		case decode.EventRetract:
+			if current != nil {
+				text := strings.TrimSuffix(current.text.String(), e.Text)
+				current.text.Reset()
+				current.text.WriteString(text)
+			}
+		default:
+			if current == nil {
+				current = &word{event: e}
+				p.words[e.Channel] = current
+			}
+			current.text.WriteString(e.Text)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/decode"
+	"github.com/rebay1982/gmorse/internal/skimmer"
+	"github.com/rebay1982/gmorse/internal/synth"
+)
+
+func Test_SkimFile(t *testing.T) {
+	// Two stations at once, the second starting later, over some noise.
+	mix := make([]int16, 8*8000)
+	for i, s := range []struct {
+		text      string
+		frequency float64
+		delay     time.Duration
+	}{
+		{"CQ TEST", 700, 500 * time.Millisecond},
+		{"DE W1AW", 1200, 1500 * time.Millisecond},
+	} {
+		detections, err := decode.NewEncoder(decode.EncoderConfig{Wpm: 20}).Encode(s.text)
+		if err != nil {
+			t.Fatalf("expecting no error for station %d, got %v", i, err)
+		}
+		synthesizer := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: s.frequency, Amplitude: 0.3})
+		samples := append(synthesizer.Silence(s.delay), synthesizer.Render(detections)...)
+		for j := range min(len(mix), len(samples)) {
+			mix[j] += samples[j]
+		}
+	}
+
+	mix = synth.NewChannel(synth.ChannelConfig{SampleRate: 8000, SNR: 30, Noise: true, Seed: 1}).Apply(mix)
+
+	file := filepath.Join(t.TempDir(), "band.wav")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+	if err := synth.WriteWAV(f, 8000, mix); err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+	f.Close()
+
+	output := strings.Builder{}
+	if err := skimFile(file, skimmer.Config{Decoder: decode.DecoderConfig{Wpm: 20, Tolerace: 0.4}}, &output); err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+
+	words := map[string]float64{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			t.Fatalf("expecting time, channel, frequency, speed and word, got [%s]", line)
+		}
+		frequency, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "Hz"), 64)
+		if err != nil {
+			t.Fatalf("expecting a frequency, got [%s]", line)
+		}
+		words[fields[4]] = frequency
+	}
+
+	for word, frequency := range map[string]float64{"CQ": 700, "TEST": 700, "DE": 1200, "W1AW": 1200} {
+		if math.Abs(words[word]-frequency) > 5 {
+			t.Errorf("expecting [%s] at %gHz, got\n%s", word, frequency, output.String())
+		}
+	}
+}
@@ -1,54 +1,25 @@
 package main
 
 import (
-	"encoding/binary"
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"os"
 	"os/signal"
-	"strconv"
 	"time"
 
-	"github.com/gen2brain/malgo"
-	"github.com/rebay1982/gdsp/fft"
-	"github.com/rebay1982/gdsp/windowing"
+	"github.com/rebay1982/gmorse/internal/audio"
+	"github.com/rebay1982/gmorse/internal/detect"
 )
 
 func main() {
-
-	// Setup malgo
-	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
-	if err != nil {
-		fmt.Println("Did not work...")
-	}
-	defer func() {
-		_ = ctx.Uninit()
-		ctx.Free()
-	}()
-
-	// List capture devices
-	fmt.Println("")
-	fmt.Println("Capture devices:")
-	cDevs, err := ctx.Devices(malgo.Capture)
-	for i, cDev := range cDevs {
-		fmt.Printf("%d: %s, default: %d\n", i, cDev.Name(), cDev.IsDefault)
-	}
-
-	fmt.Println("-- Select input device: ")
-	var strDevId string
-	fmt.Scanln(&strDevId)
-
-	selectedDevId, err := strconv.Atoi(strDevId)
+	device, err := audio.SelectDevice(os.Stdin, os.Stdout)
 	if err != nil {
-		fmt.Println("Bad user input, exiting...")
-		os.Exit(1)
-	}
-	if selectedDevId < 0 || selectedDevId >= len(cDevs) {
-		fmt.Printf("Bad user input, expecting value between 0 and %d, got %d\n", len(cDevs), selectedDevId)
+		fmt.Println("Could not select the device:", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("\n\n--- Initializing capture on default device ---")
 	// Setup device to validate capture.
 	const (
 		sampleRate = 8000
@@ -56,52 +27,19 @@ func main() {
 		toneFreq   = 800.0
 	)
 
-	deviceConfig := malgo.DefaultDeviceConfig(malgo.Capture)
-	selInfo := cDevs[1]
-	deviceId := selInfo.ID
-
-	deviceConfig.Capture.DeviceID = deviceId.Pointer()
-	deviceConfig.Capture.Format = malgo.FormatS16
-	deviceConfig.Capture.Channels = 1
-	deviceConfig.SampleRate = sampleRate
-	deviceConfig.Alsa.NoMMap = 1
+	source := audio.NewDevice(audio.DeviceConfig{Device: device, SampleRate: sampleRate})
 
 	// Avoid recreating these every time the onReceiveFrames function is called.
 	samples := make([]float64, blockSize)
-	fspec := make([]complex128, blockSize)
-	normalizedMags := make([]float64, blockSize)
-	onReceiveFrames := func(_, iSamples []byte, sampleCount uint32) {
+	spectrum := detect.NewSpectrum(blockSize)
+	onReceiveFrames := func(block audio.Block) {
 		startTime := time.Now()
 
 		// Normalize
-		for i := range sampleCount {
-			samples[i] = fft.NormalizePCM16(int16(binary.LittleEndian.Uint16(iSamples[i<<1 : (i+1)<<1])))
-		}
+		samples = block.Samples(0, samples[:0])
+		sampleCount := len(samples)
 
-		// Window (reduces spectral leakage)
-		// Only apply it to the samples, not the padding.
-		windowing.Hann(samples[:sampleCount])
-
-		// Window (reduces spectral leakage)
-		// Only apply it to the samples, not the padding.
-		windowing.Hann(samples[:sampleCount])
-
-		for i, s := range samples {
-			fspec[i] = complex(s, 0)
-		}
-
-		//freqSpectrum := fft.RecursiveFFT(fspec)
-		fft.IterativeFFT(fspec)
-		freqSpectrum := fspec
-
-		hannFactorRMS := windowing.HannFactorRMS(int(sampleCount))
-		halfSampleCount := int(sampleCount >> 1)
-		for i := 1; i < int(sampleCount)-1; i++ {
-			// Normalize magnitudes and take into account the hanning window that was applied on the input samples before FFT.
-			normalizedMags[i] = 2.0 * (fft.ComputeMagnitude(freqSpectrum[i]) / float64(sampleCount)) / hannFactorRMS
-		}
-		normalizedMags[0] = (fft.ComputeMagnitude(freqSpectrum[0]) / float64(sampleCount)) / hannFactorRMS
-		normalizedMags[halfSampleCount-1] = (fft.ComputeMagnitude(freqSpectrum[halfSampleCount-1]) / float64(sampleCount)) / hannFactorRMS
+		normalizedMags := spectrum.Compute(samples)
 
 		timeDiff := time.Now().Sub(startTime)
 		fmt.Printf("Processed %d in %d us          \n", sampleCount, timeDiff/time.Microsecond)
@@ -127,19 +65,14 @@ func main() {
 		fmt.Print("\033[21A\r")
 	}
 
-	captureCallbacks := malgo.DeviceCallbacks{
-		Data: onReceiveFrames,
-	}
-
-	device, err := malgo.InitDevice(ctx.Context, deviceConfig, captureCallbacks)
-	defer device.Uninit()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
 	fmt.Println("\n\n--- Initializing capture on default device ---")
-	device.Start()
-
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
-	<-sig
+	if err := source.Run(ctx, onReceiveFrames); err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Println("\nCapture failed:", err)
+		os.Exit(1)
+	}
 
 	fmt.Println("\nExiting...")
 }
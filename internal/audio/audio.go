@@ -0,0 +1,130 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Format Encoding of the samples of a block, little-endian.
+type Format int
+
+const (
+	// FormatS16 Signed 16-bit integers, as captured by default.
+	FormatS16 Format = iota
+	// FormatU8 Unsigned 8-bit integers, centered on 128.
+	FormatU8
+	// FormatS24 Signed 24-bit integers, packed in 3 bytes.
+	FormatS24
+	// FormatS32 Signed 32-bit integers.
+	FormatS32
+	// FormatF32 32-bit floats, in [-1, 1].
+	FormatF32
+)
+
+// Size Bytes per sample.
+func (f Format) Size() int {
+	switch f {
+	case FormatU8:
+		return 1
+	case FormatS16:
+		return 2
+	case FormatS24:
+		return 3
+	case FormatS32, FormatF32:
+		return 4
+	}
+	return 0
+}
+
+func (f Format) String() string {
+	switch f {
+	case FormatU8:
+		return "u8"
+	case FormatS16:
+		return "s16"
+	case FormatS24:
+		return "s24"
+	case FormatS32:
+		return "s32"
+	case FormatF32:
+		return "f32"
+	}
+	return "invalid"
+}
+
+// ParseFormat Format of the given name, as printed (u8, s16, s24, s32 or f32).
+func ParseFormat(name string) (Format, error) {
+	for _, f := range []Format{FormatU8, FormatS16, FormatS24, FormatS32, FormatF32} {
+		if f.String() == name {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown format %q, expecting u8, s16, s24, s32 or f32", name)
+}
+
+// Block Audio handed over by a source at once.
+type Block struct {
+	// Data Frames of interleaved samples, one per channel.
+	Data       []byte
+	Format     Format
+	Channels   int
+	SampleRate int
+
+	// Time Offset of the first frame from the start of the audio, counted in frames rather than from the clock.
+	Time time.Duration
+}
+
+// Frames Number of frames in the block.
+func (b Block) Frames() int {
+	if b.Channels <= 0 || b.Format.Size() == 0 {
+		return 0
+	}
+	return len(b.Data) / (b.Format.Size() * b.Channels)
+}
+
+// Samples Samples of the channel (from 0), normalized to [-1, 1], appended to samples.
+func (b Block) Samples(channel int, samples []float64) []float64 {
+	size := b.Format.Size()
+	stride := size * b.Channels
+	for i := range b.Frames() {
+		samples = append(samples, sample(b.Data[i*stride+channel*size:], b.Format))
+	}
+	return samples
+}
+
+// sample Normalize the sample at the start of data.
+func sample(data []byte, format Format) float64 {
+	switch format {
+	case FormatU8:
+		return (float64(data[0]) - 128) / 128
+	case FormatS16:
+		return float64(int16(binary.LittleEndian.Uint16(data))) / math.MaxInt16
+	case FormatS24:
+		// Sign extend from the top byte.
+		v := int32(data[0]) | int32(data[1])<<8 | int32(int8(data[2]))<<16
+		return float64(v) / (1<<23 - 1)
+	case FormatS32:
+		return float64(int32(binary.LittleEndian.Uint32(data))) / math.MaxInt32
+	case FormatF32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+	}
+	return 0
+}
+
+// Handler Receives the blocks of a source. The block data is only valid until the handler returns.
+type Handler func(block Block)
+
+// Source Audio coming in block after block, from a sound card, a file or anything else.
+type Source interface {
+	// Run Hand the audio over to the handler, block after block, until it runs out, the source fails or the context is
+	// done. The handler is called from a single goroutine, one block at a time.
+	Run(ctx context.Context, handler Handler) error
+}
+
+// duration Time taken by a number of frames.
+func duration(frames, sampleRate int) time.Duration {
+	return time.Duration(float64(frames) / float64(sampleRate) * float64(time.Second))
+}
@@ -0,0 +1,66 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func Test_BlockSamples(t *testing.T) {
+	f32 := func(v float32) []byte {
+		return binary.LittleEndian.AppendUint32(nil, math.Float32bits(v))
+	}
+
+	testCases := []struct {
+		name   string
+		format Format
+		// Two stereo frames.
+		data []byte
+		exp  []float64
+	}{
+		{name: "u8", format: FormatU8, data: []byte{192, 0, 64, 0}, exp: []float64{0.5, -0.5}},
+		{
+			name:   "s16",
+			format: FormatS16,
+			data:   []byte{0xff, 0x3f, 0, 0, 0x01, 0xc0, 0, 0},
+			exp:    []float64{16383.0 / 32767, -16383.0 / 32767},
+		},
+		{
+			name:   "s24",
+			format: FormatS24,
+			data:   []byte{0xff, 0xff, 0x3f, 0, 0, 0, 0x01, 0x00, 0xc0, 0, 0, 0},
+			exp:    []float64{4194303.0 / 8388607, -4194303.0 / 8388607},
+		},
+		{
+			name:   "s32",
+			format: FormatS32,
+			data:   []byte{0xff, 0xff, 0xff, 0x3f, 0, 0, 0, 0, 0x01, 0, 0, 0xc0, 0, 0, 0, 0},
+			exp:    []float64{1073741823.0 / 2147483647, -1073741823.0 / 2147483647},
+		},
+		{
+			name:   "f32",
+			format: FormatF32,
+			data:   append(append(append(f32(0.25), f32(1)...), f32(-0.75)...), f32(1)...),
+			exp:    []float64{0.25, -0.75},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			block := Block{Data: tc.data, Format: tc.format, Channels: 2, SampleRate: 8000}
+			if block.Frames() != 2 {
+				t.Fatalf("expecting 2 frames, got %d", block.Frames())
+			}
+
+			samples := block.Samples(0, nil)
+			if len(samples) != len(tc.exp) {
+				t.Fatalf("expecting %v, got %v", tc.exp, samples)
+			}
+			for i := range samples {
+				if math.Abs(samples[i]-tc.exp[i]) > 1e-6 {
+					t.Errorf("expecting %v, got %v", tc.exp, samples)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gen2brain/malgo"
+)
+
+const defaultPeriod = 10 * time.Millisecond
+
+// DeviceInfo A capture device of the system.
+type DeviceInfo struct {
+	Name    string
+	Default bool
+
+	id malgo.DeviceID
+}
+
+// CaptureDevices Capture devices of the system.
+func CaptureDevices() ([]DeviceInfo, error) {
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = ctx.Uninit()
+		ctx.Free()
+	}()
+
+	infos, err := ctx.Devices(malgo.Capture)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]DeviceInfo, len(infos))
+	for i, info := range infos {
+		devices[i] = DeviceInfo{Name: info.Name(), Default: info.IsDefault != 0, id: info.ID}
+	}
+	return devices, nil
+}
+
+// DeviceConfig What to capture from which device.
+type DeviceConfig struct {
+	// Device Device to capture from, the default one when nil.
+	Device *DeviceInfo
+
+	SampleRate int
+	// Channels Channels captured, defaults to 1.
+	Channels int
+	// Format Format of the samples captured, defaults to signed 16-bit.
+	Format Format
+	// Period Audio handed over at once, defaults to 10ms.
+	Period time.Duration
+}
+
+// Device Audio source capturing from a sound card, through malgo.
+type Device struct {
+	config DeviceConfig
+}
+
+// NewDevice Create a source capturing from a device.
+func NewDevice(cfg DeviceConfig) *Device {
+	if cfg.Channels <= 0 {
+		cfg.Channels = 1
+	}
+	if cfg.Period <= 0 {
+		cfg.Period = defaultPeriod
+	}
+
+	return &Device{config: cfg}
+}
+
+// Run Capture until the context is done, handing the audio over from the device callback.
+func (d *Device) Run(ctx context.Context, handler Handler) error {
+	format, ok := malgoFormats[d.config.Format]
+	if !ok {
+		return fmt.Errorf("unsupported capture format %s", d.config.Format)
+	}
+
+	mctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = mctx.Uninit()
+		mctx.Free()
+	}()
+
+	deviceConfig := malgo.DefaultDeviceConfig(malgo.Capture)
+	if d.config.Device != nil {
+		deviceConfig.Capture.DeviceID = d.config.Device.id.Pointer()
+	}
+	deviceConfig.Capture.Format = format
+	deviceConfig.Capture.Channels = uint32(d.config.Channels)
+	deviceConfig.SampleRate = uint32(d.config.SampleRate)
+	deviceConfig.PeriodSizeInMilliseconds = uint32(d.config.Period.Milliseconds())
+	deviceConfig.Alsa.NoMMap = 1
+
+	// Timing from the frames captured so far.
+	frames := 0
+	callbacks := malgo.DeviceCallbacks{
+		Data: func(_, input []byte, frameCount uint32) {
+			block := Block{
+				Data:       input[:int(frameCount)*d.config.Channels*d.config.Format.Size()],
+				Format:     d.config.Format,
+				Channels:   d.config.Channels,
+				SampleRate: d.config.SampleRate,
+				Time:       duration(frames, d.config.SampleRate),
+			}
+			frames += int(frameCount)
+			handler(block)
+		},
+	}
+
+	device, err := malgo.InitDevice(mctx.Context, deviceConfig, callbacks)
+	if err != nil {
+		return err
+	}
+	defer device.Uninit()
+
+	if err := device.Start(); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	if err := device.Stop(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+var malgoFormats = map[Format]malgo.FormatType{
+	FormatU8:  malgo.FormatU8,
+	FormatS16: malgo.FormatS16,
+	FormatS24: malgo.FormatS24,
+	FormatS32: malgo.FormatS32,
+	FormatF32: malgo.FormatF32,
+}
+
+// SelectDevice List the capture devices on out and read the number of the one to capture from on in.
+func SelectDevice(in io.Reader, out io.Writer) (*DeviceInfo, error) {
+	devices, err := CaptureDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(out, "")
+	fmt.Fprintln(out, "Capture devices:")
+	for i, device := range devices {
+		fmt.Fprintf(out, "%d: %s, default: %v\n", i, device.Name, device.Default)
+	}
+
+	fmt.Fprintln(out, "-- Select input device: ")
+	var selected int
+	if _, err := fmt.Fscanln(in, &selected); err != nil {
+		return nil, fmt.Errorf("bad input: %w", err)
+	}
+	if selected < 0 || selected >= len(devices) {
+		return nil, fmt.Errorf("expecting a device between 0 and %d, got %d", len(devices)-1, selected)
+	}
+	return &devices[selected], nil
+}
@@ -0,0 +1,148 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+)
+
+const (
+	defaultIQBandwidth = 500.0
+	defaultIQPitch     = 700.0
+	defaultIQRate      = 8000
+)
+
+// IQConfig Which signal to take out of complex baseband and how it sounds.
+type IQConfig struct {
+	// Offset Hz of the signal from the center of the baseband, negative below it.
+	Offset float64
+	// Bandwidth Hz around the signal let through, defaults to 500Hz. Narrower keeps more noise and neighbours out,
+	// wider lets the signal drift.
+	Bandwidth float64
+	// Pitch Audio frequency the carrier of the signal comes out at (the beat frequency oscillator), defaults to 700Hz.
+	Pitch float64
+	// SampleRate Rate of the audio produced, defaults to 8000Hz.
+	SampleRate int
+}
+
+// IQ Audio source demodulating CW out of complex baseband (IQ) audio, as SDRs produce. The signal is mixed down to 0Hz,
+// decimated and filtered narrowly with the rest of the band, then mixed back up to the pitch as a product detector
+// would, giving mono audio for the tone detector. The blocks of the source hold I on the first channel and Q on the
+// second.
+type IQ struct {
+	config IQConfig
+	source Source
+}
+
+// NewIQ Create a source demodulating the signal out of the IQ audio of source.
+func NewIQ(source Source, cfg IQConfig) *IQ {
+	if cfg.Bandwidth <= 0 {
+		cfg.Bandwidth = defaultIQBandwidth
+	}
+	if cfg.Pitch <= 0 {
+		cfg.Pitch = defaultIQPitch
+	}
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = defaultIQRate
+	}
+
+	return &IQ{config: cfg, source: source}
+}
+
+// Run Hand the demodulated audio over to the handler, as 32-bit floats, until the source is done. Blocks of the
+// source that aren't IQ (two channels) end it with an error.
+func (q *IQ) Run(ctx context.Context, handler Handler) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// I then Q through each stage, created for the rate of the first block. The mixer and BFO phases are in cycles and
+	// carry on from block to block.
+	var decimators, filters [2]*Resampler
+	var samples, decimated, filtered [2][]float64
+	var mixer, bfo float64
+	var data []byte
+	frames := 0
+	var failed error
+
+	err := q.source.Run(ctx, func(block Block) {
+		if failed != nil {
+			return
+		}
+		if block.Channels != 2 {
+			failed = fmt.Errorf("expecting IQ audio on 2 channels, got %d", block.Channels)
+			cancel()
+			return
+		}
+
+		if decimators[0] == nil {
+			// Pass half the bandwidth each side of the signal and stop from the bandwidth on, with as many taps as the
+			// transition takes (Blackman).
+			taps := int(math.Ceil(11*float64(q.config.SampleRate)/q.config.Bandwidth)) | 1
+			coefficients := lowPass(taps, 0.75*q.config.Bandwidth/float64(q.config.SampleRate))
+			for c := range decimators {
+				decimators[c] = NewResampler(block.SampleRate, q.config.SampleRate)
+				filters[c] = newFilter(coefficients)
+			}
+		}
+
+		// Mix the signal down to 0Hz, multiplying by e^-j2πft.
+		samples[0] = block.Samples(0, samples[0][:0])
+		samples[1] = block.Samples(1, samples[1][:0])
+		step := q.config.Offset / float64(block.SampleRate)
+		for n, re := range samples[0] {
+			sin, cos := math.Sincos(2 * math.Pi * mixer)
+			im := samples[1][n]
+			samples[0][n], samples[1][n] = re*cos+im*sin, im*cos-re*sin
+			mixer = math.Mod(mixer+step, 1)
+		}
+
+		for c := range decimators {
+			decimated[c] = decimators[c].Process(samples[c], decimated[c][:0])
+			filtered[c] = filters[c].Process(decimated[c], filtered[c][:0])
+		}
+		if len(filtered[0]) == 0 {
+			return
+		}
+
+		// Back up to the pitch, keeping the real part.
+		data = data[:0]
+		step = q.config.Pitch / float64(q.config.SampleRate)
+		for n, re := range filtered[0] {
+			sin, cos := math.Sincos(2 * math.Pi * bfo)
+			sample := re*cos - filtered[1][n]*sin
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(sample)))
+			bfo = math.Mod(bfo+step, 1)
+		}
+		handler(Block{
+			Data:       data,
+			Format:     FormatF32,
+			Channels:   1,
+			SampleRate: q.config.SampleRate,
+			Time:       duration(frames, q.config.SampleRate),
+		})
+		frames += len(filtered[0])
+	})
+
+	if failed != nil {
+		return failed
+	}
+	return err
+}
+
+// newFilter Filter with the coefficients, as a resampler that keeps the rate.
+func newFilter(coefficients []float64) *Resampler {
+	taps := len(coefficients)
+	phases := slices.Clone(coefficients)
+	slices.Reverse(phases)
+
+	return &Resampler{
+		up:      1,
+		down:    1,
+		taps:    taps,
+		phases:  phases,
+		history: make([]float64, taps-1),
+		next:    taps - 1,
+	}
+}
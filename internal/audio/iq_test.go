@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+)
+
+// complexTone One second of IQ at the frequency from the center and rate, interleaved as float blocks of 10ms.
+func complexTone(frequency float64, sampleRate int) [][]byte {
+	data := []byte{}
+	for i := range sampleRate {
+		phase := 2 * math.Pi * frequency * float64(i) / float64(sampleRate)
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(0.5*math.Cos(phase))))
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(0.5*math.Sin(phase))))
+	}
+	return slices.Collect(slices.Chunk(data, sampleRate/100*8))
+}
+
+// iqBlocks Source of IQ float blocks.
+type iqBlocks struct {
+	sampleRate int
+	blocks     [][]byte
+	channels   int
+}
+
+func (b iqBlocks) Run(_ context.Context, handler Handler) error {
+	for _, data := range b.blocks {
+		handler(Block{Data: data, Format: FormatF32, Channels: b.channels, SampleRate: b.sampleRate})
+	}
+	return nil
+}
+
+func Test_IQ(t *testing.T) {
+	testCases := []struct {
+		name      string
+		frequency float64
+		// Level expected at the audio frequency, or at most when attenuated.
+		audio      float64
+		level      float64
+		attenuated bool
+	}{
+		{name: "carrier", frequency: 10000, audio: 700, level: 0},
+		{name: "above", frequency: 10200, audio: 900, level: 0},
+		{name: "below", frequency: 9900, audio: 600, level: 0},
+		// Same distance from the center, on the other side.
+		{name: "image", frequency: -10000, audio: 700, level: -60, attenuated: true},
+		{name: "outside", frequency: 11000, audio: 1700, level: -60, attenuated: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := NewIQ(iqBlocks{sampleRate: 48000, blocks: complexTone(tc.frequency, 48000), channels: 2},
+				IQConfig{Offset: 10000})
+
+			samples := []float64{}
+			if err := source.Run(context.Background(), func(block Block) {
+				if block.Channels != 1 || block.SampleRate != 8000 {
+					t.Fatalf("expecting mono blocks at 8000Hz, got %d channels at %dHz", block.Channels, block.SampleRate)
+				}
+				samples = block.Samples(0, samples)
+			}); err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+			if len(samples) < 7900 {
+				t.Fatalf("expecting about 8000 samples, got %d", len(samples))
+			}
+
+			// Skip the filter delays.
+			l := level(samples[800:], tc.audio, 8000)
+			if tc.attenuated && l > tc.level {
+				t.Errorf("expecting %gHz below %gdB, got %.1fdB", tc.audio, tc.level, l)
+			}
+			if !tc.attenuated && math.Abs(l-tc.level) > 0.5 {
+				t.Errorf("expecting %gHz at %gdB, got %.1fdB", tc.audio, tc.level, l)
+			}
+		})
+	}
+}
+
+func Test_IQChannels(t *testing.T) {
+	source := NewIQ(iqBlocks{sampleRate: 8000, blocks: [][]byte{make([]byte, 80)}, channels: 1}, IQConfig{})
+	if err := source.Run(context.Background(), func(Block) {
+		t.Errorf("expecting no block")
+	}); err == nil {
+		t.Errorf("expecting an error for mono audio")
+	}
+}
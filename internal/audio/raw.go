@@ -0,0 +1,75 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// RawConfig Layout of raw PCM audio, which has no header to tell.
+type RawConfig struct {
+	Format     Format
+	Channels   int
+	SampleRate int
+
+	// Period Audio handed over at once, defaults to 10ms.
+	Period time.Duration
+}
+
+// Raw Audio source reading raw interleaved PCM, e.g. from stdin at the end of a pipeline, as fast as it comes and is
+// consumed.
+type Raw struct {
+	config RawConfig
+	r      io.Reader
+}
+
+// NewRaw Create a source reading raw PCM laid out as configured. Channels defaults to 1.
+func NewRaw(r io.Reader, cfg RawConfig) *Raw {
+	if cfg.Channels <= 0 {
+		cfg.Channels = 1
+	}
+	if cfg.Period <= 0 {
+		cfg.Period = defaultPeriod
+	}
+
+	return &Raw{config: cfg, r: r}
+}
+
+// Run Hand the audio over to the handler, one period at a time, until the input ends or the context is done. Periods
+// are handed over once full, or at the end. A truncated last frame is dropped.
+func (s *Raw) Run(ctx context.Context, handler Handler) error {
+	frameSize := s.config.Format.Size() * s.config.Channels
+	if frameSize == 0 {
+		return fmt.Errorf("unsupported format %s", s.config.Format)
+	}
+	frames := max(int(s.config.Period.Seconds()*float64(s.config.SampleRate)), 1)
+	buffer := make([]byte, frames*frameSize)
+
+	position := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n, err := io.ReadFull(s.r, buffer)
+		if n -= n % frameSize; n > 0 {
+			handler(Block{
+				Data:       buffer[:n],
+				Format:     s.config.Format,
+				Channels:   s.config.Channels,
+				SampleRate: s.config.SampleRate,
+				Time:       duration(position, s.config.SampleRate),
+			})
+			position += n / frameSize
+		}
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
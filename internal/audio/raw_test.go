@@ -0,0 +1,78 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_Raw(t *testing.T) {
+	// Stereo float frames, the last one cut short as when a pipe is closed mid-frame.
+	data := []byte{}
+	for i := range 250 {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(i)/1000))
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(-float32(i)/1000))
+	}
+	data = append(data, 0, 0, 0)
+
+	// Read through a pipe, a few bytes at a time.
+	r, w := io.Pipe()
+	go func() {
+		for chunk := range slices.Chunk(data, 7) {
+			w.Write(chunk)
+		}
+		w.Close()
+	}()
+
+	source := NewRaw(r, RawConfig{Format: FormatF32, Channels: 2, SampleRate: 8000, Period: 10 * time.Millisecond})
+	samples := []float64{}
+	periods := 0
+	if err := source.Run(context.Background(), func(block Block) {
+		if exp := time.Duration(periods) * 10 * time.Millisecond; block.Time != exp {
+			t.Errorf("expecting period %d at %v, got %v", periods, exp, block.Time)
+		}
+		periods++
+		samples = block.Samples(1, samples)
+	}); err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+
+	if periods != 4 || len(samples) != 250 {
+		t.Fatalf("expecting 250 frames in 4 periods, got %d in %d", len(samples), periods)
+	}
+	for i, s := range samples {
+		if math.Abs(s+float64(i)/1000) > 1e-6 {
+			t.Fatalf("expecting %g at %d, got %g", -float64(i)/1000, i, s)
+		}
+	}
+}
+
+func Test_RawCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	source := NewRaw(bytes.NewReader(make([]byte, 8000)), RawConfig{Format: FormatS16, SampleRate: 8000})
+
+	periods := 0
+	err := source.Run(ctx, func(Block) {
+		periods++
+		cancel()
+	})
+	if err != context.Canceled || periods != 1 {
+		t.Errorf("expecting to stop after the first period, got %v after %d", err, periods)
+	}
+}
+
+func Test_ParseFormat(t *testing.T) {
+	for _, f := range []Format{FormatU8, FormatS16, FormatS24, FormatS32, FormatF32} {
+		if parsed, err := ParseFormat(f.String()); err != nil || parsed != f {
+			t.Errorf("expecting %s, got %s (%v)", f, parsed, err)
+		}
+	}
+	if _, err := ParseFormat("s8"); err == nil {
+		t.Error("expecting an error for an unknown format")
+	}
+}
@@ -0,0 +1,171 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+)
+
+// Taps of the anti-alias filter per unit of the larger of the interpolation and decimation factors. The filter passes
+// up to 80% of the lower Nyquist frequency and stops from it on, by about 74dB (Blackman window).
+const resamplerTaps = 55
+
+// Resampler Converts samples from one rate to another by a rational factor, up by interpolating and down by
+// decimating, low-pass filtered against aliasing. The filter is split into phases so only the outputs kept are
+// computed, and only from the input samples (polyphase).
+type Resampler struct {
+	up, down int
+	// Taps per phase, and the filter coefficients, phase after phase, each reversed to run over the input in order.
+	taps   int
+	phases []float64
+
+	// Latest input samples, the taps-1 needed for the next output first, and the position of the next output at the
+	// interpolated rate, from the start of history.
+	history []float64
+	next    int
+}
+
+// NewResampler Create a resampler from the input to the output rate.
+func NewResampler(inRate, outRate int) *Resampler {
+	g := gcd(inRate, outRate)
+	up, down := outRate/g, inRate/g
+
+	factor := max(up, down)
+	taps := max(resamplerTaps*factor/up, 1)
+	length := up * taps
+
+	// Cut off in the middle of the transition, at the interpolated rate, with a gain of up to make up for the zeros
+	// interpolating puts between the samples.
+	prototype := lowPass(length, 0.45/float64(factor))
+
+	// Output at interpolated position t is the sum of h[t%up + k*up] x[t/up - k], phase t%up holds these h reversed.
+	phases := make([]float64, length)
+	for phase := range up {
+		for k := range taps {
+			phases[phase*taps+taps-1-k] = prototype[phase+k*up] * float64(up)
+		}
+	}
+
+	return &Resampler{
+		up:      up,
+		down:    down,
+		taps:    taps,
+		phases:  phases,
+		history: make([]float64, taps-1),
+		next:    (taps - 1) * up,
+	}
+}
+
+// Process Resample the next samples, appending the output to out. Outputs are produced as soon as the input they need
+// is in, delayed by half the filter.
+func (r *Resampler) Process(samples []float64, out []float64) []float64 {
+	r.history = append(r.history, samples...)
+
+	for r.next/r.up < len(r.history) {
+		newest := r.next / r.up
+		coefficients := r.phases[(r.next%r.up)*r.taps:][:r.taps]
+		y := 0.0
+		for k, x := range r.history[newest-r.taps+1 : newest+1] {
+			y += coefficients[k] * x
+		}
+		out = append(out, y)
+		r.next += r.down
+	}
+
+	// Keep what the next output needs.
+	if drop := r.next/r.up - (r.taps - 1); drop > 0 {
+		drop = min(drop, len(r.history))
+		r.history = append(r.history[:0], r.history[drop:]...)
+		r.next -= drop * r.up
+	}
+	return out
+}
+
+// lowPass Coefficients of a low-pass filter of the given length, cut off at a fraction of the sample rate, with a gain
+// of 1. Windowed sinc (Blackman).
+func lowPass(length int, cutoff float64) []float64 {
+	coefficients := make([]float64, length)
+	sum := 0.0
+	for i := range coefficients {
+		t := float64(i) - float64(length-1)/2
+		sinc := 2 * cutoff
+		if t != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*t) / (math.Pi * t)
+		}
+		x := 2 * math.Pi * float64(i) / float64(max(length-1, 1))
+		coefficients[i] = sinc * (0.42 - 0.5*math.Cos(x) + 0.08*math.Cos(2*x))
+		sum += coefficients[i]
+	}
+
+	for i := range coefficients {
+		coefficients[i] /= sum
+	}
+	return coefficients
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// Resampled Audio source converting the blocks of another to a sample rate, as 32-bit floats. Blocks already at the
+// rate are handed over as they are.
+type Resampled struct {
+	source     Source
+	sampleRate int
+}
+
+// NewResampled Create a source converting the audio of source to the sample rate.
+func NewResampled(source Source, sampleRate int) *Resampled {
+	return &Resampled{source: source, sampleRate: sampleRate}
+}
+
+// Run Hand the resampled audio over to the handler until the source is done.
+func (r *Resampled) Run(ctx context.Context, handler Handler) error {
+	// One resampler per channel, created for the rate of the first block.
+	var resamplers []*Resampler
+	var samples, resampled [][]float64
+	var data []byte
+	frames := 0
+
+	return r.source.Run(ctx, func(block Block) {
+		if block.SampleRate == r.sampleRate {
+			handler(block)
+			return
+		}
+
+		if resamplers == nil {
+			resamplers = make([]*Resampler, block.Channels)
+			samples = make([][]float64, block.Channels)
+			resampled = make([][]float64, block.Channels)
+			for c := range resamplers {
+				resamplers[c] = NewResampler(block.SampleRate, r.sampleRate)
+			}
+		}
+
+		for c, resampler := range resamplers {
+			samples[c] = block.Samples(c, samples[c][:0])
+			resampled[c] = resampler.Process(samples[c], resampled[c][:0])
+		}
+		if len(resampled[0]) == 0 {
+			return
+		}
+
+		data = data[:0]
+		for i := range resampled[0] {
+			for c := range resampled {
+				data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(resampled[c][i])))
+			}
+		}
+		handler(Block{
+			Data:       data,
+			Format:     FormatF32,
+			Channels:   len(resamplers),
+			SampleRate: r.sampleRate,
+			Time:       duration(frames, r.sampleRate),
+		})
+		frames += len(resampled[0])
+	})
+}
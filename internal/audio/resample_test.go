@@ -0,0 +1,121 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// tone One second of a sine at the frequency and rate.
+func tone(frequency float64, sampleRate int) []float64 {
+	samples := make([]float64, sampleRate)
+	for i := range samples {
+		samples[i] = 0.5 * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate))
+	}
+	return samples
+}
+
+// level RMS level of a tone at the frequency in the samples, in dB relative to the amplitude of the test tones.
+func level(samples []float64, frequency float64, sampleRate int) float64 {
+	re, im := 0.0, 0.0
+	for i, s := range samples {
+		re += s * math.Cos(2*math.Pi*frequency*float64(i)/float64(sampleRate))
+		im += s * math.Sin(2*math.Pi*frequency*float64(i)/float64(sampleRate))
+	}
+	return 20 * math.Log10(2*math.Hypot(re, im)/float64(len(samples))/0.5)
+}
+
+func Test_Resampler(t *testing.T) {
+	testCases := []struct {
+		name    string
+		inRate  int
+		outRate int
+	}{
+		{name: "48kHz", inRate: 48000, outRate: 8000},
+		{name: "44.1kHz", inRate: 44100, outRate: 8000},
+		{name: "96kHz", inRate: 96000, outRate: 8000},
+		{name: "11.025kHz", inRate: 11025, outRate: 8000},
+		{name: "up", inRate: 8000, outRate: 16000},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, c := range []struct {
+				frequency float64
+				// Level expected, or at most when attenuated.
+				level      float64
+				attenuated bool
+			}{
+				{frequency: 700, level: 0},
+				{frequency: 2500, level: 0},
+				// Would alias at 3kHz.
+				{frequency: 5000, level: -60, attenuated: true},
+			} {
+				if c.frequency >= float64(tc.inRate)/2 || (c.attenuated && c.frequency < float64(tc.outRate)/2) {
+					continue
+				}
+
+				// In blocks of 10ms, as they would come, then skip the filter delay.
+				resampler := NewResampler(tc.inRate, tc.outRate)
+				out := []float64{}
+				for block := range slices.Chunk(tone(c.frequency, tc.inRate), tc.inRate/100) {
+					out = resampler.Process(block, out)
+				}
+				if exp := tc.outRate; math.Abs(float64(len(out)-exp)) > float64(exp)/50 {
+					t.Fatalf("expecting about %d samples, got %d", exp, len(out))
+				}
+				out = out[len(out)/10:]
+
+				l := level(out, c.frequency, tc.outRate)
+				if c.attenuated && l > c.level {
+					t.Errorf("expecting %gHz below %gdB, got %.1fdB", c.frequency, c.level, l)
+				}
+				if !c.attenuated && math.Abs(l-c.level) > 0.5 {
+					t.Errorf("expecting %gHz at %gdB, got %.1fdB", c.frequency, c.level, l)
+				}
+			}
+		})
+	}
+}
+
+// blocks Source of float blocks.
+type blocks struct {
+	sampleRate int
+	samples    []float64
+	period     int
+}
+
+func (b blocks) Run(_ context.Context, handler Handler) error {
+	for chunk := range slices.Chunk(b.samples, b.period) {
+		data := []byte{}
+		for _, s := range chunk {
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(s)))
+		}
+		handler(Block{Data: data, Format: FormatF32, Channels: 1, SampleRate: b.sampleRate})
+	}
+	return nil
+}
+
+func Test_Resampled(t *testing.T) {
+	source := NewResampled(blocks{sampleRate: 48000, samples: tone(700, 48000), period: 480}, 8000)
+
+	samples := []float64{}
+	if err := source.Run(context.Background(), func(block Block) {
+		if block.SampleRate != 8000 {
+			t.Fatalf("expecting blocks at 8000Hz, got %dHz", block.SampleRate)
+		}
+		if exp := time.Duration(len(samples)) * time.Second / 8000; block.Time != exp {
+			t.Errorf("expecting a block at %v, got %v", exp, block.Time)
+		}
+		samples = block.Samples(0, samples)
+	}); err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+
+	if l := level(samples[800:], 700, 8000); len(samples) < 7900 || math.Abs(l) > 0.5 {
+		t.Errorf("expecting a second of 700Hz at 0dB, got %d samples at %.1fdB", len(samples), l)
+	}
+}
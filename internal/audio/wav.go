@@ -0,0 +1,184 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xfffe
+)
+
+// WAVConfig How the audio of a WAV file is handed over.
+type WAVConfig struct {
+	// Period Audio handed over at once, defaults to 10ms.
+	Period time.Duration
+}
+
+// WAV Audio source reading a WAV file, as fast as it is consumed. 8, 16, 24 and 32-bit PCM and 32-bit float are read,
+// with any number of channels and at any sample rate.
+type WAV struct {
+	config WAVConfig
+	r      io.Reader
+
+	format     Format
+	channels   int
+	sampleRate int
+	// The data chunk, read as raw PCM.
+	data *Raw
+}
+
+// NewWAV Create a source reading the WAV file, the header is read right away.
+func NewWAV(r io.Reader, cfg WAVConfig) (*WAV, error) {
+	w := &WAV{config: cfg, r: r}
+	if err := w.readHeader(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Format Format of the samples in the file.
+func (w *WAV) Format() Format {
+	return w.format
+}
+
+// Channels Number of channels in the file.
+func (w *WAV) Channels() int {
+	return w.channels
+}
+
+// SampleRate Sample rate of the file.
+func (w *WAV) SampleRate() int {
+	return w.sampleRate
+}
+
+// readHeader Go through the chunks up to the data, reading the format on the way.
+func (w *WAV) readHeader() error {
+	riff := struct {
+		ChunkID   [4]byte
+		ChunkSize uint32
+		Format    [4]byte
+	}{}
+	if err := binary.Read(w.r, binary.LittleEndian, &riff); err != nil {
+		return fmt.Errorf("wav: %w", err)
+	}
+	if string(riff.ChunkID[:]) != "RIFF" || string(riff.Format[:]) != "WAVE" {
+		return errors.New("wav: not a RIFF/WAVE file")
+	}
+
+	haveFormat := false
+	for {
+		chunk := struct {
+			ID   [4]byte
+			Size uint32
+		}{}
+		if err := binary.Read(w.r, binary.LittleEndian, &chunk); err != nil {
+			return fmt.Errorf("wav: no data: %w", err)
+		}
+		// Chunks are padded to an even size.
+		size := int64(chunk.Size + chunk.Size%2)
+
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			read, err := w.readFormat(size)
+			if err != nil {
+				return err
+			}
+			haveFormat = true
+			size -= read
+
+		case "data":
+			if !haveFormat {
+				return errors.New("wav: data before format")
+			}
+			// Streaming writers leave the size at 0 or all ones, not knowing it yet. Read to the end then.
+			data := w.r
+			if chunk.Size != 0 && chunk.Size != 0xffffffff {
+				data = io.LimitReader(w.r, int64(chunk.Size))
+			}
+			w.data = NewRaw(data, RawConfig{
+				Format:     w.format,
+				Channels:   w.channels,
+				SampleRate: w.sampleRate,
+				Period:     w.config.Period,
+			})
+			return nil
+		}
+
+		if _, err := io.CopyN(io.Discard, w.r, size); err != nil {
+			return fmt.Errorf("wav: %w", err)
+		}
+	}
+}
+
+// readFormat Read the format chunk of the given size, returning how many of its bytes were read.
+func (w *WAV) readFormat(size int64) (int64, error) {
+	format := struct {
+		AudioFormat   uint16
+		NumChannels   uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+	}{}
+	if size < 16 {
+		return 0, errors.New("wav: format chunk too short")
+	}
+	if err := binary.Read(w.r, binary.LittleEndian, &format); err != nil {
+		return 0, fmt.Errorf("wav: %w", err)
+	}
+	read := int64(16)
+
+	// The extensible format holds the actual one in the first two bytes of its sub-format GUID.
+	audioFormat := format.AudioFormat
+	if audioFormat == wavFormatExtensible && size >= 40 {
+		extension := struct {
+			Size          uint16
+			ValidBits     uint16
+			ChannelMask   uint32
+			SubFormat     uint16
+			SubFormatRest [14]byte
+		}{}
+		if err := binary.Read(w.r, binary.LittleEndian, &extension); err != nil {
+			return 0, fmt.Errorf("wav: %w", err)
+		}
+		read += 24
+		audioFormat = extension.SubFormat
+	}
+
+	switch {
+	case audioFormat == wavFormatPCM && format.BitsPerSample == 8:
+		w.format = FormatU8
+	case audioFormat == wavFormatPCM && format.BitsPerSample == 16:
+		w.format = FormatS16
+	case audioFormat == wavFormatPCM && format.BitsPerSample == 24:
+		w.format = FormatS24
+	case audioFormat == wavFormatPCM && format.BitsPerSample == 32:
+		w.format = FormatS32
+	case audioFormat == wavFormatFloat && format.BitsPerSample == 32:
+		w.format = FormatF32
+	default:
+		return 0, fmt.Errorf("wav: unsupported format %d with %d bits per sample", audioFormat, format.BitsPerSample)
+	}
+	if format.NumChannels == 0 || format.SampleRate == 0 {
+		return 0, fmt.Errorf("wav: %d channels at %dHz", format.NumChannels, format.SampleRate)
+	}
+	w.channels = int(format.NumChannels)
+	w.sampleRate = int(format.SampleRate)
+
+	return read, nil
+}
+
+// Run Hand the audio over to the handler, one period at a time, until the file ends or the context is done.
+func (w *WAV) Run(ctx context.Context, handler Handler) error {
+	if err := w.data.Run(ctx, handler); err != nil {
+		return fmt.Errorf("wav: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// wavFile WAV file of the given format holding the data, with a LIST chunk before it as some writers put there.
+func wavFile(audioFormat uint16, bits, channels, sampleRate int, extensible bool, dataSize uint32, data []byte) []byte {
+	b := &bytes.Buffer{}
+	le := binary.LittleEndian
+
+	format := &bytes.Buffer{}
+	tag := audioFormat
+	if extensible {
+		tag = wavFormatExtensible
+	}
+	binary.Write(format, le, tag)
+	binary.Write(format, le, uint16(channels))
+	binary.Write(format, le, uint32(sampleRate))
+	binary.Write(format, le, uint32(sampleRate*channels*bits/8))
+	binary.Write(format, le, uint16(channels*bits/8))
+	binary.Write(format, le, uint16(bits))
+	if extensible {
+		binary.Write(format, le, uint16(22))
+		binary.Write(format, le, uint16(bits))
+		binary.Write(format, le, uint32(0))
+		binary.Write(format, le, audioFormat)
+		format.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71})
+	}
+
+	b.WriteString("RIFF")
+	binary.Write(b, le, uint32(0))
+	b.WriteString("WAVE")
+	b.WriteString("fmt ")
+	binary.Write(b, le, uint32(format.Len()))
+	b.Write(format.Bytes())
+	b.WriteString("LIST")
+	binary.Write(b, le, uint32(3))
+	b.Write([]byte{1, 2, 3, 0})
+	b.WriteString("data")
+	binary.Write(b, le, dataSize)
+	b.Write(data)
+	return b.Bytes()
+}
+
+func Test_WAV(t *testing.T) {
+	f32 := []byte{}
+	for _, v := range []float32{0.5, -0.25, -0.5, 0.25, 0.125, 1} {
+		f32 = binary.LittleEndian.AppendUint32(f32, math.Float32bits(v))
+	}
+
+	testCases := []struct {
+		name       string
+		file       []byte
+		format     Format
+		channels   int
+		sampleRate int
+		exp        []float64
+	}{
+		{
+			name:       "u8_mono",
+			file:       wavFile(wavFormatPCM, 8, 1, 8000, false, 3, []byte{192, 64, 128, 0}),
+			format:     FormatU8,
+			channels:   1,
+			sampleRate: 8000,
+			exp:        []float64{0.5, -0.5, 0},
+		},
+		{
+			name:       "s16_stereo",
+			file:       wavFile(wavFormatPCM, 16, 2, 44100, false, 8, []byte{0xff, 0x3f, 0, 0, 0x01, 0xc0, 0, 0}),
+			format:     FormatS16,
+			channels:   2,
+			sampleRate: 44100,
+			exp:        []float64{16383.0 / 32767, -16383.0 / 32767},
+		},
+		{
+			name:       "s24_extensible",
+			file:       wavFile(wavFormatPCM, 24, 1, 48000, true, 6, []byte{0xff, 0xff, 0x3f, 0x01, 0x00, 0xc0}),
+			format:     FormatS24,
+			channels:   1,
+			sampleRate: 48000,
+			exp:        []float64{4194303.0 / 8388607, -4194303.0 / 8388607},
+		},
+		{
+			name:       "f32_stereo_streamed",
+			file:       wavFile(wavFormatFloat, 32, 2, 96000, false, 0xffffffff, f32),
+			format:     FormatF32,
+			channels:   2,
+			sampleRate: 96000,
+			exp:        []float64{0.5, -0.5, 0.125},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wav, err := NewWAV(bytes.NewReader(tc.file), WAVConfig{})
+			if err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+			if wav.Format() != tc.format || wav.Channels() != tc.channels || wav.SampleRate() != tc.sampleRate {
+				t.Fatalf("expecting %s, %d channels at %dHz, got %s, %d channels at %dHz", tc.format, tc.channels,
+					tc.sampleRate, wav.Format(), wav.Channels(), wav.SampleRate())
+			}
+
+			samples := []float64{}
+			if err := wav.Run(context.Background(), func(block Block) {
+				samples = block.Samples(0, samples)
+			}); err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+			if len(samples) != len(tc.exp) {
+				t.Fatalf("expecting %v, got %v", tc.exp, samples)
+			}
+			for i := range samples {
+				if math.Abs(samples[i]-tc.exp[i]) > 1e-6 {
+					t.Errorf("expecting %v, got %v", tc.exp, samples)
+				}
+			}
+		})
+	}
+}
+
+func Test_WAVPeriods(t *testing.T) {
+	data := make([]byte, 2*250)
+	wav, err := NewWAV(bytes.NewReader(wavFile(wavFormatPCM, 16, 1, 8000, false, uint32(len(data)), data)),
+		WAVConfig{Period: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+
+	// Periods of 80 frames from the start of the file, the last one short.
+	frames := []int{}
+	if err := wav.Run(context.Background(), func(block Block) {
+		if exp := time.Duration(len(frames)) * 10 * time.Millisecond; block.Time != exp {
+			t.Errorf("expecting period %d at %v, got %v", len(frames), exp, block.Time)
+		}
+		frames = append(frames, block.Frames())
+	}); err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+	if len(frames) != 4 || frames[0] != 80 || frames[3] != 10 {
+		t.Errorf("expecting 3 periods of 80 frames and one of 10, got %v", frames)
+	}
+}
+
+func Test_WAVUnsupported(t *testing.T) {
+	if _, err := NewWAV(bytes.NewReader(wavFile(wavFormatFloat, 64, 1, 8000, false, 0, nil)), WAVConfig{}); err == nil {
+		t.Error("expecting an error for 64-bit float")
+	}
+	if _, err := NewWAV(bytes.NewReader([]byte("RIFX")), WAVConfig{}); err == nil {
+		t.Error("expecting an error for a truncated file")
+	}
+}
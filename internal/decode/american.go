@@ -0,0 +1,180 @@
+package decode
+
+import (
+	"math"
+	"time"
+)
+
+// American (railroad) morse elements, as written in the American table. On top of dots and dashes, it has two longer
+// dashes (L and 0) and a space inside some characters (e.g. C is ".. .").
+const (
+	americanDot           = '.'
+	americanDash          = '-'
+	americanLongDash      = '_'
+	americanExtraLongDash = '='
+	americanSpace         = ' '
+)
+
+// Markers for the gaps ending a character or a word, never part of a code.
+const (
+	americanCharGap = 'c'
+	americanWordGap = 'w'
+)
+
+type americanElement struct {
+	element byte
+	units   float64
+}
+
+// Element and gap lengths, in units (dot lengths), for American morse.
+var (
+	americanMarks = []americanElement{
+		{americanDot, 1},
+		{americanDash, 2},
+		{americanLongDash, 4},
+		{americanExtraLongDash, 6},
+	}
+
+	americanSpaces = []americanElement{
+		{0, 1}, // Between elements, nothing to record.
+		{americanSpace, 2},
+		{americanCharGap, 3},
+		{americanWordGap, 6},
+	}
+)
+
+// American The historic landline code used by North American telegraphers, decoded with ModeAmerican.
+var American = &CodeTable{
+	Name: "american",
+	Chars: map[rune]string{
+		'A': ".-",
+		'B': "-...",
+		'C': ".. .",
+		'D': "-..",
+		'E': ".",
+		'F': ".-.",
+		'G': "--.",
+		'H': "....",
+		'I': "..",
+		'J': "-.-.",
+		'K': "-.-",
+		'L': "_",
+		'M': "--",
+		'N': "-.",
+		'O': ". .",
+		'P': ".....",
+		'Q': "..-.",
+		'R': ". ..",
+		'S': "...",
+		'T': "-",
+		'U': "..-",
+		'V': "...-",
+		'W': ".--",
+		'X': ".-..",
+		'Y': ".. ..",
+		'Z': "... .",
+		'&': ". ...",
+		'1': ".--.",
+		'2': "..-..",
+		'3': "...-.",
+		'4': "....-",
+		'5': "---",
+		'6': "......",
+		'7': "--..",
+		'8': "-....",
+		'9': "-..-",
+		'0': "=",
+		'.': "..--..",
+		',': ".-.-",
+		'?': "-..-.",
+		'!': "---.",
+	},
+}
+
+var americanLookup = invertTable(American)
+
+// decodeAmerican Accumulate American morse elements and emit characters on character and word gaps. Since there are
+// more than two kinds of marks and spaces, each duration is classified as the closest element rather than with a tree.
+func (md *MorseDecoder) decodeAmerican(d Detection) {
+	if d.State {
+		element, ok := md.closestAmericanElement(d.Duration, americanMarks)
+		if !ok {
+			// Can't interpret, the character will come out as unknown.
+			element = '?'
+			md.confidence = 0
+		}
+		md.pattern += string(element)
+
+		return
+	}
+
+	element, ok := md.closestAmericanElement(d.Duration, americanSpaces)
+
+	// Nothing received yet, a long enough silence ends the transmission.
+	if md.pattern == "" {
+		if !ok {
+			md.endTransmission()
+		}
+		return
+	}
+
+	switch element {
+	case 0:
+		// Between elements, wait for the next one.
+	case americanSpace:
+		md.pattern += string(americanSpace)
+	case americanCharGap:
+		md.flushAmerican()
+	case americanWordGap:
+		md.outputAmerican(true)
+		if !ok {
+			md.endTransmission()
+		}
+	}
+}
+
+// flushAmerican Emit the character being received, if any.
+func (md *MorseDecoder) flushAmerican() {
+	if md.pattern != "" {
+		md.outputAmerican(false)
+	}
+}
+
+func (md *MorseDecoder) outputAmerican(wordEnd bool) {
+	char, ok := americanLookup[md.pattern]
+	if !ok {
+		md.unknown()
+		return
+	}
+	md.emit(EventChar, string(char), wordEnd)
+}
+
+// closestAmericanElement Find the element whose length is closest to the duration, in the log domain. Marks longer than
+// the longest element (within tolerance) can't be interpreted, long spaces are always word gaps.
+func (md *MorseDecoder) closestAmericanElement(d time.Duration, elements []americanElement) (byte, bool) {
+	ditLength := md.currentDitLength()
+	units := float64(d) / float64(time.Millisecond) / ditLength
+
+	longest := elements[len(elements)-1]
+	if units > longest.units*(1+md.config.Tolerace) {
+		return longest.element, false
+	}
+
+	closest := elements[0]
+	for _, e := range elements[1:] {
+		if math.Abs(math.Log(units/e.units)) < math.Abs(math.Log(units/closest.units)) {
+			closest = e
+		}
+	}
+	md.rate(d, closest.units*ditLength)
+
+	return closest.element, true
+}
+
+func invertTable(table *CodeTable) map[string]rune {
+	lookup := make(map[string]rune, len(table.Chars))
+	for char, code := range table.Chars {
+		lookup[code] = char
+	}
+	return lookup
+}
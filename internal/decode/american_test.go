@@ -0,0 +1,62 @@
+package decode
+
+import (
+	"testing"
+	"time"
+)
+
+// americanToDetections Turn an American morse string into detections. A space is the intra-character space, a bar
+// separates characters and a slash separates words.
+func americanToDetections(code string, dotLength time.Duration) []Detection {
+	units := map[byte]time.Duration{'.': 1, '-': 2, '_': 4, '=': 6}
+
+	detections := []Detection{}
+	for i := range len(code) {
+		length, ok := units[code[i]]
+		if !ok {
+			continue
+		}
+		detections = append(detections, Detection{State: true, Duration: length * dotLength})
+
+		gap := dotLength
+		if i+1 >= len(code) || code[i+1] == '|' {
+			gap = 3 * dotLength
+		} else if code[i+1] == '/' {
+			gap = 6 * dotLength
+		} else if code[i+1] == ' ' {
+			gap = 2 * dotLength
+		}
+		detections = append(detections, Detection{State: false, Duration: gap})
+	}
+
+	return detections
+}
+
+func Test_DecodeAmerican(t *testing.T) {
+	dotLength := 60 * time.Millisecond
+
+	testCases := []struct {
+		name string
+		code string
+		exp  string
+	}{
+		{name: "intra_character_spaces", code: ".. .|. .|. ..", exp: "COR"},
+		{name: "long_dashes", code: "_|=|.--.", exp: "L01"},
+		{name: "full_words", code: "... .|.|.|-../.-.|. .|. ..", exp: "ZEED FOR"},
+		{name: "unknown_code", code: "..--|.", exp: "|?|E"},
+	}
+
+	config := DecoderConfig{
+		Wpm:      20,
+		Tolerace: 0.4,
+		Mode:     ModeAmerican,
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, text := runDecoder(config, americanToDetections(tc.code, dotLength)); text != tc.exp {
+				t.Errorf("expecting [%s], got [%s]", tc.exp, text)
+			}
+		})
+	}
+}
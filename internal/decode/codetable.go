@@ -0,0 +1,327 @@
+package decode
+
+import "maps"
+
+// CodeTable Maps characters and prosigns to their dit/dah codes. Tables are selected through DecoderConfig.Table and
+// can be supplied by callers for alphabets not shipped here.
+type CodeTable struct {
+	Name     string
+	Chars    map[rune]string
+	Prosigns map[string]string
+
+	// Shifts Prosigns (by name) switching the decoder to another table, e.g. DO switching to Wabun. A nil table switches
+	// back to the table the decoder was configured with. Shift prosigns are always recognised, even if Prosigns is off.
+	Shifts map[string]*CodeTable
+}
+
+var digits = map[rune]string{
+	'1': ".----",
+	'2': "..---",
+	'3': "...--",
+	'4': "....-",
+	'5': ".....",
+	'6': "-....",
+	'7': "--...",
+	'8': "---..",
+	'9': "----.",
+	'0': "-----",
+}
+
+var standardProsigns = map[string]string{
+	"AR":  ".-.-.",
+	"AS":  ".-...",
+	"BT":  "-...-",
+	"CT":  "-.-.-",
+	"KN":  "-.--.",
+	"SK":  "...-.-",
+	"SN":  "...-.",
+	"SOS": "...---...",
+	"HH":  "........",
+}
+
+// ITU International (Latin) morse code, the default table.
+var ITU = &CodeTable{
+	Name: "itu",
+	Chars: withDigits(map[rune]string{
+		'A':  ".-",
+		'B':  "-...",
+		'C':  "-.-.",
+		'D':  "-..",
+		'E':  ".",
+		'F':  "..-.",
+		'G':  "--.",
+		'H':  "....",
+		'I':  "..",
+		'J':  ".---",
+		'K':  "-.-",
+		'L':  ".-..",
+		'M':  "--",
+		'N':  "-.",
+		'O':  "---",
+		'P':  ".--.",
+		'Q':  "--.-",
+		'R':  ".-.",
+		'S':  "...",
+		'T':  "-",
+		'U':  "..-",
+		'V':  "...-",
+		'W':  ".--",
+		'X':  "-..-",
+		'Y':  "-.--",
+		'Z':  "--..",
+		'.':  ".-.-.-",
+		',':  "--..--",
+		'?':  "..--..",
+		'!':  "-.-.--",
+		':':  "---...",
+		'"':  ".-..-.",
+		'\'': ".----.",
+		'=':  "-...-",
+		'/':  "-..-.",
+		'(':  "-.--.",
+		')':  "-.--.-",
+		'&':  ".-...",
+		';':  "-.-.-.",
+		'+':  ".-.-.",
+		'-':  "-....-",
+		'_':  "..--.-",
+		'$':  "...-..-",
+		'@':  ".--.-.",
+	}),
+	Prosigns: standardProsigns,
+}
+
+// Russian Cyrillic morse code.
+var Russian = &CodeTable{
+	Name: "russian",
+	Chars: withDigits(map[rune]string{
+		'А': ".-",
+		'Б': "-...",
+		'В': ".--",
+		'Г': "--.",
+		'Д': "-..",
+		'Е': ".",
+		'Ж': "...-",
+		'З': "--..",
+		'И': "..",
+		'Й': ".---",
+		'К': "-.-",
+		'Л': ".-..",
+		'М': "--",
+		'Н': "-.",
+		'О': "---",
+		'П': ".--.",
+		'Р': ".-.",
+		'С': "...",
+		'Т': "-",
+		'У': "..-",
+		'Ф': "..-.",
+		'Х': "....",
+		'Ц': "-.-.",
+		'Ч': "---.",
+		'Ш': "----",
+		'Щ': "--.-",
+		'Ъ': "--.--",
+		'Ы': "-.--",
+		'Ь': "-..-",
+		'Э': "..-..",
+		'Ю': "..--",
+		'Я': ".-.-",
+		'.': "......",
+		',': ".-.-.-",
+		'?': "..--..",
+	}),
+	Prosigns: standardProsigns,
+}
+
+// Greek morse code.
+var Greek = &CodeTable{
+	Name: "greek",
+	Chars: withDigits(map[rune]string{
+		'Α': ".-",
+		'Β': "-...",
+		'Γ': "--.",
+		'Δ': "-..",
+		'Ε': ".",
+		'Ζ': "--..",
+		'Η': "....",
+		'Θ': "-.-.",
+		'Ι': "..",
+		'Κ': "-.-",
+		'Λ': ".-..",
+		'Μ': "--",
+		'Ν': "-.",
+		'Ξ': "-..-",
+		'Ο': "---",
+		'Π': ".--.",
+		'Ρ': ".-.",
+		'Σ': "...",
+		'Τ': "-",
+		'Υ': "-.--",
+		'Φ': "..-.",
+		'Χ': "----",
+		'Ψ': "--.-",
+		'Ω': ".--",
+	}),
+	Prosigns: standardProsigns,
+}
+
+// Hebrew morse code.
+var Hebrew = &CodeTable{
+	Name: "hebrew",
+	Chars: withDigits(map[rune]string{
+		'א': ".-",
+		'ב': "-...",
+		'ג': "--.",
+		'ד': "-..",
+		'ה': "---",
+		'ו': ".",
+		'ז': "--..",
+		'ח': "....",
+		'ט': "..-",
+		'י': "..",
+		'כ': "-.-",
+		'ל': ".-..",
+		'מ': "--",
+		'נ': "-.",
+		'ס': "-.-.",
+		'ע': ".---",
+		'פ': ".--.",
+		'צ': ".--",
+		'ק': "--.-",
+		'ר': ".-.",
+		'ש': "...",
+		'ת': "-",
+	}),
+	Prosigns: standardProsigns,
+}
+
+// Arabic morse code.
+var Arabic = &CodeTable{
+	Name: "arabic",
+	Chars: withDigits(map[rune]string{
+		'ا': ".-",
+		'ب': "-...",
+		'ت': "-",
+		'ث': "-.-.",
+		'ج': ".---",
+		'ح': "....",
+		'خ': "---",
+		'د': "-..",
+		'ذ': "--..",
+		'ر': ".-.",
+		'ز': "---.",
+		'س': "...",
+		'ش': "----",
+		'ص': "-..-",
+		'ض': "...-",
+		'ط': "..-",
+		'ظ': "-.--",
+		'ع': ".-.-",
+		'غ': "--.",
+		'ف': "..-.",
+		'ق': "--.-",
+		'ك': "-.-",
+		'ل': ".-..",
+		'م': "--",
+		'ن': "-.",
+		'ه': "..-..",
+		'و': ".--",
+		'ي': "..",
+		'ء': ".",
+	}),
+	Prosigns: standardProsigns,
+}
+
+// Wabun Japanese kana morse code. Most codes collide with the standard prosigns, so only SN (back to the configured
+// table) and the error prosign are kept.
+var Wabun = &CodeTable{
+	Name: "wabun",
+	Chars: withDigits(map[rune]string{
+		'ア': "--.--",
+		'イ': ".-",
+		'ウ': "..-",
+		'エ': "-.---",
+		'オ': ".-...",
+		'カ': ".-..",
+		'キ': "-.-..",
+		'ク': "...-",
+		'ケ': "-.--",
+		'コ': "----",
+		'サ': "-.-.-",
+		'シ': "--.-.",
+		'ス': "---.-",
+		'セ': ".---.",
+		'ソ': "---.",
+		'タ': "-.",
+		'チ': "..-.",
+		'ツ': ".--.",
+		'テ': ".-.--",
+		'ト': "..-..",
+		'ナ': ".-.",
+		'ニ': "-.-.",
+		'ヌ': "....",
+		'ネ': "--.-",
+		'ノ': "..--",
+		'ハ': "-...",
+		'ヒ': "--..-",
+		'フ': "--..",
+		'ヘ': ".",
+		'ホ': "-..",
+		'マ': "-..-",
+		'ミ': "..-.-",
+		'ム': "-",
+		'メ': "-...-",
+		'モ': "-..-.",
+		'ヤ': ".--",
+		'ユ': "-..--",
+		'ヨ': "--",
+		'ラ': "...",
+		'リ': "--.",
+		'ル': "-.--.",
+		'レ': "---",
+		'ロ': ".-.-",
+		'ワ': "-.-",
+		'ヰ': ".-..-",
+		'ヱ': ".--..",
+		'ヲ': ".---",
+		'ン': ".-.-.",
+		'゛': "..",
+		'゜': "..--.",
+		'ー': ".--.-",
+		'、': ".-.-.-",
+		'」': ".-.-..",
+		'（': "-.--.-",
+		'）': ".-..-.",
+	}),
+	Prosigns: map[string]string{
+		"SN": "...-.",
+		"HH": "........",
+	},
+	Shifts: map[string]*CodeTable{
+		"SN": nil,
+	},
+}
+
+// Japanese International code where the DO prosign shifts to Wabun, until SN shifts back.
+var Japanese = &CodeTable{
+	Name:  "japanese",
+	Chars: ITU.Chars,
+	Prosigns: merge(standardProsigns, map[string]string{
+		"DO": "-..---",
+	}),
+	Shifts: map[string]*CodeTable{
+		"DO": Wabun,
+	},
+}
+
+func withDigits(chars map[rune]string) map[rune]string {
+	maps.Copy(chars, digits)
+	return chars
+}
+
+func merge(a, b map[string]string) map[string]string {
+	merged := maps.Clone(a)
+	maps.Copy(merged, b)
+	return merged
+}
@@ -0,0 +1,72 @@
+package decode
+
+import (
+	"testing"
+)
+
+func Test_CodeTables(t *testing.T) {
+	tables := []*CodeTable{ITU, Russian, Greek, Hebrew, Arabic, Wabun, Japanese}
+
+	for _, table := range tables {
+		t.Run(table.Name, func(t *testing.T) {
+			seen := map[string]rune{}
+			for char, code := range table.Chars {
+				for i := range len(code) {
+					if code[i] != '.' && code[i] != '-' {
+						t.Errorf("expecting only dits and dahs for [%c], got [%s]", char, code)
+					}
+				}
+
+				if other, ok := seen[code]; ok {
+					t.Errorf("expecting unique codes, [%c] and [%c] both use [%s]", char, other, code)
+				}
+				seen[code] = char
+			}
+
+			for prosign := range table.Shifts {
+				if _, ok := table.Prosigns[prosign]; !ok {
+					t.Errorf("expecting shift prosign [%s] to have a code", prosign)
+				}
+			}
+		})
+	}
+}
+
+func Test_DecodeAlphabets(t *testing.T) {
+	custom := &CodeTable{
+		Name: "custom",
+		Chars: map[rune]string{
+			'α': ".",
+			'β': "-",
+		},
+	}
+
+	testCases := []struct {
+		name  string
+		table *CodeTable
+		exp   string
+	}{
+		{name: "russian", table: Russian, exp: "МОРЗЕ"},
+		{name: "greek", table: Greek, exp: "ΜΟΡΣΟ"},
+		{name: "hebrew", table: Hebrew, exp: "מהרש"},
+		{name: "arabic", table: Arabic, exp: "مخرس"},
+		{name: "wabun", table: Wabun, exp: "イウコワム"},
+		{name: "japanese_shifts", table: Japanese, exp: "CQ <DO> イウ <SN> A"},
+		{name: "custom", table: custom, exp: "αβα"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := DecoderConfig{
+				Wpm:      25,
+				Tolerace: 0.4,
+				Table:    tc.table,
+			}
+
+			detections := encode(t, EncoderConfig{Wpm: 25, Table: tc.table}, tc.exp)
+			if _, text := runDecoder(config, detections); text != tc.exp {
+				t.Errorf("expecting [%s], got [%s]", tc.exp, text)
+			}
+		})
+	}
+}
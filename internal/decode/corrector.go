@@ -0,0 +1,235 @@
+package decode
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+)
+
+const (
+	defaultBeamWidth     = 16
+	defaultMinConfidence = 0.5
+
+	// Maximum edit distance between what was received and a candidate code, for unknown and doubtful characters.
+	maxUnknownDistance  = 2
+	maxDoubtfulDistance = 1
+
+	// Cost taken off a word that makes sense: a known word, Q-code, abbreviation or a valid callsign. Also given, in a
+	// smaller amount, to partial words that could still become one.
+	wordBonus   = 2.5
+	prefixBonus = 0.5
+)
+
+// callsignGrammar Amateur callsigns: a prefix (one or two letters, or a digit and a letter, or a letter and a digit), a
+// single digit and a suffix of up to four letters, with an optional portable designator.
+var callsignGrammar = regexp.MustCompile(`^([A-Z]{1,2}|[0-9][A-Z]|[A-Z][0-9])[0-9][A-Z]{1,4}(/[A-Z0-9]{1,4})?$`)
+
+// defaultWords Q-codes, common CW abbreviations and words heard in a QSO.
+var defaultWords = []string{
+	"QRL", "QRM", "QRN", "QRO", "QRP", "QRQ", "QRS", "QRT", "QRU", "QRV", "QRX", "QRZ", "QSB", "QSK", "QSL", "QSO",
+	"QSP", "QSY", "QTH", "QTR",
+	"CQ", "DE", "K", "KN", "BK", "SK", "AR", "R", "TU", "TNX", "TKS", "FB", "OM", "YL", "XYL", "UR", "RST", "ES", "HR",
+	"HW", "CPY", "AGN", "PSE", "NAME", "NR", "OP", "RIG", "ANT", "PWR", "WX", "GM", "GA", "GE", "GN", "GL", "CUL",
+	"CU", "73", "88", "599", "5NN", "579", "559", "TEST", "DX", "ABT", "SRI", "WID", "WKD", "BURO", "LOTW", "INFO",
+	"THE", "AND", "FOR", "YOU", "ARE", "IS", "IN", "IT", "TO", "OF", "ON", "AT", "WITH", "HERE", "VERY", "GOOD", "SIGNAL",
+	"THANKS", "POWER", "WATTS", "DIPOLE", "VERTICAL", "YAGI", "SUNNY", "CLOUDY", "RAIN", "SNOW", "TEMP",
+}
+
+// CorrectorConfig How hard the corrector works and which words it knows about.
+type CorrectorConfig struct {
+	// Words Extra words (upper case) to consider on top of the built-in Q-codes and abbreviations.
+	Words []string
+	// BeamWidth Candidate interpretations kept while going through a word.
+	BeamWidth int
+	// MinConfidence Characters decoded with a lower confidence are reconsidered. Unknown sequences always are.
+	MinConfidence float64
+}
+
+// Corrector Post-decoding stage re-ranking the possible interpretations of a word with doubtful characters, using a
+// word list and the amateur callsign grammar. Set it on DecoderConfig.Corrector to have words corrected as they come.
+type Corrector struct {
+	config CorrectorConfig
+	table  *CodeTable
+
+	words    map[string]struct{}
+	prefixes map[string]struct{}
+}
+
+type candidate struct {
+	text string
+	kind EventKind
+	cost float64
+}
+
+type hypothesis struct {
+	choices []candidate
+	text    string
+	cost    float64
+}
+
+// NewCorrector Create a corrector for words decoded with the given code table (ITU if nil).
+func NewCorrector(table *CodeTable, cfg CorrectorConfig) *Corrector {
+	if table == nil {
+		table = ITU
+	}
+	if cfg.BeamWidth <= 0 {
+		cfg.BeamWidth = defaultBeamWidth
+	}
+	if cfg.MinConfidence <= 0 {
+		cfg.MinConfidence = defaultMinConfidence
+	}
+
+	c := &Corrector{
+		config:   cfg,
+		table:    table,
+		words:    map[string]struct{}{},
+		prefixes: map[string]struct{}{},
+	}
+	for _, word := range slices.Concat(defaultWords, cfg.Words) {
+		c.words[word] = struct{}{}
+		for i := 1; i <= len(word); i++ {
+			c.prefixes[word[:i]] = struct{}{}
+		}
+	}
+
+	return c
+}
+
+// Correct Pick the best interpretation of a word, given as the character, prosign and unknown events decoded for it.
+// Events are returned with their text (and kind, for unknown sequences) replaced, they are left untouched if nothing
+// better than what was decoded comes up.
+func (c *Corrector) Correct(word []Event) []Event {
+	beam := []hypothesis{{}}
+	for _, e := range word {
+		candidates := c.candidates(e)
+
+		next := make([]hypothesis, 0, len(beam)*len(candidates))
+		for _, h := range beam {
+			for _, cand := range candidates {
+				text := h.text + cand.text
+				cost := h.cost + cand.cost
+				if c.couldBeWord(text) {
+					cost -= prefixBonus
+				}
+
+				next = append(next, hypothesis{
+					choices: append(slices.Clone(h.choices), cand),
+					text:    text,
+					cost:    cost,
+				})
+			}
+		}
+
+		slices.SortStableFunc(next, func(a, b hypothesis) int { return cmp.Compare(a.cost, b.cost) })
+		beam = next[:min(len(next), c.config.BeamWidth)]
+	}
+
+	// Re-rank the complete words, partial word bonuses no longer matter.
+	best, bestCost := beam[0], 0.0
+	for i, h := range beam {
+		cost := 0.0
+		for _, cand := range h.choices {
+			cost += cand.cost
+		}
+		if c.isWord(h.text) {
+			cost -= wordBonus
+		}
+
+		if i == 0 || cost < bestCost {
+			best, bestCost = h, cost
+		}
+	}
+
+	corrected := slices.Clone(word)
+	for i, cand := range best.choices {
+		corrected[i].Text = cand.text
+		corrected[i].Kind = cand.kind
+	}
+
+	return corrected
+}
+
+// candidates Possible interpretations of a decoded event, with their cost. Confident characters and prosigns are kept
+// as they are, doubtful characters and unknown sequences bring in the codes that are close to what was received.
+func (c *Corrector) candidates(e Event) []candidate {
+	doubtful := e.Kind == EventChar && e.Confidence < c.config.MinConfidence
+	if e.Kind != EventUnknown && !doubtful {
+		return []candidate{{text: e.Text, kind: e.Kind}}
+	}
+
+	maxDistance := float64(maxUnknownDistance)
+	candidates := []candidate{}
+	if doubtful {
+		maxDistance = maxDoubtfulDistance
+		candidates = append(candidates, candidate{text: e.Text, kind: e.Kind})
+	}
+
+	for char, code := range c.table.Chars {
+		if string(char) == e.Text {
+			continue
+		}
+
+		// The more confident the decoder was, the more it costs to go against it.
+		if d := patternDistance(e.Pattern, code); d <= maxDistance {
+			candidates = append(candidates, candidate{text: string(char), kind: EventChar, cost: d * (1 + e.Confidence)})
+		}
+	}
+
+	if e.Kind == EventUnknown && len(candidates) == 0 {
+		candidates = append(candidates, candidate{text: e.Text, kind: e.Kind})
+	}
+
+	// Keep the order stable, map iteration isn't.
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		return cmp.Or(cmp.Compare(a.cost, b.cost), cmp.Compare(a.text, b.text))
+	})
+
+	return candidates
+}
+
+func (c *Corrector) isWord(text string) bool {
+	_, ok := c.words[text]
+	return ok || callsignGrammar.MatchString(text)
+}
+
+// couldBeWord Whether the partial word is the start of a known word or of a callsign.
+func (c *Corrector) couldBeWord(text string) bool {
+	if _, ok := c.prefixes[text]; ok {
+		return true
+	}
+
+	// Any callsign prefix followed by a digit starts a callsign, try the shortest possible suffix.
+	return callsignGrammar.MatchString(text) || callsignGrammar.MatchString(text+"A")
+}
+
+// patternDistance Edit distance between a received pattern and a code. Elements that could not be interpreted ('?')
+// match anything and cost half as much to drop.
+func patternDistance(pattern, code string) float64 {
+	prev := make([]float64, len(code)+1)
+	curr := make([]float64, len(code)+1)
+	for j := range prev {
+		prev[j] = float64(j)
+	}
+
+	for i := 1; i <= len(pattern); i++ {
+		dropCost := 1.0
+		if pattern[i-1] == '?' {
+			dropCost = 0.5
+		}
+
+		curr[0] = prev[0] + dropCost
+		for j := 1; j <= len(code); j++ {
+			substitution := 0.0
+			if pattern[i-1] == '?' {
+				substitution = 0.5
+			} else if pattern[i-1] != code[j-1] {
+				substitution = 1
+			}
+
+			curr[j] = min(prev[j-1]+substitution, prev[j]+dropCost, curr[j-1]+1)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(code)]
+}
@@ -0,0 +1,97 @@
+package decode
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Correct(t *testing.T) {
+	char := func(text, pattern string, confidence float64) Event {
+		return Event{Kind: EventChar, Text: text, Pattern: pattern, Confidence: confidence}
+	}
+	unknown := func(pattern string) Event {
+		return Event{Kind: EventUnknown, Text: "|?|", Pattern: pattern}
+	}
+
+	testCases := []struct {
+		name string
+		word []Event
+		exp  string
+	}{
+		{
+			name: "confident_word_untouched",
+			word: []Event{char("T", "-", 1), char("E", ".", 1), char("S", "...", 1), char("R", ".-.", 1)},
+			exp:  "TESR",
+		},
+		{
+			name: "unknown_in_word",
+			word: []Event{char("N", "-.", 1), char("A", ".-", 1), unknown("-?"), char("E", ".", 1)},
+			exp:  "NAME",
+		},
+		{
+			name: "unknown_in_callsign",
+			word: []Event{char("W", ".--", 1), unknown(".----?"), char("A", ".-", 1), char("W", ".--", 1)},
+			exp:  "W1AW",
+		},
+		{
+			name: "doubtful_character_in_q_code",
+			word: []Event{char("Q", "--.-", 1), char("E", ".", 0.3), char("H", "....", 1)},
+			exp:  "QTH",
+		},
+		{
+			name: "doubtful_character_kept",
+			word: []Event{char("C", "-.-.", 1), char("Q", "--.-", 0.4)},
+			exp:  "CQ",
+		},
+		{
+			name: "prosign_untouched",
+			word: []Event{{Kind: EventProsign, Text: "<SK>", Pattern: "...-.-", Confidence: 0.1}},
+			exp:  "<SK>",
+		},
+	}
+
+	corrector := NewCorrector(ITU, CorrectorConfig{})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			corrected := corrector.Correct(tc.word)
+
+			if text := Text(corrected); text != tc.exp {
+				t.Errorf("expecting [%s], got [%s]", tc.exp, text)
+			}
+			for i, e := range corrected {
+				if e.Pattern != tc.word[i].Pattern {
+					t.Errorf("expecting pattern [%s] to be kept, got [%s]", tc.word[i].Pattern, e.Pattern)
+				}
+			}
+		})
+	}
+}
+
+func Test_DecodeWithCorrector(t *testing.T) {
+	ditLength := 48 * time.Millisecond
+
+	detections := encode(t, EncoderConfig{Wpm: 25}, "CQ CQ DE W1AW")
+
+	// Hold the last dah of the 1 for way too long, it can't be interpreted.
+	detections[54].Duration = 10 * ditLength
+	if !detections[54].State {
+		t.Fatalf("expecting the detection to be a mark")
+	}
+
+	config := DecoderConfig{
+		Wpm:      25,
+		Tolerace: 0.4,
+	}
+
+	exp := "CQ CQ DE W|?|AW"
+	if _, text := runDecoder(config, detections); text != exp {
+		t.Errorf("expecting [%s] without a corrector, got [%s]", exp, text)
+	}
+
+	config.Corrector = NewCorrector(ITU, CorrectorConfig{})
+	exp = "CQ CQ DE W1AW"
+	if _, text := runDecoder(config, detections); text != exp {
+		t.Errorf("expecting [%s] with a corrector, got [%s]", exp, text)
+	}
+}
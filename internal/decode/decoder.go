@@ -1,30 +1,160 @@
 package decode
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"iter"
+	"math"
+	"slices"
+	"sync"
 	"time"
 )
 
 // TODO: Make this with Generics?
 type treeNode struct {
-	char  byte
-	left  *treeNode
-	right *treeNode
+	char    rune
+	prosign string
+	left    *treeNode
+	right   *treeNode
+}
+
+// symbol Text emitted for the node. Prosigns are wrapped in angle brackets to set them apart from plain characters.
+func (n *treeNode) symbol() string {
+	if n.prosign != "" {
+		return "<" + n.prosign + ">"
+	}
+	return string(n.char)
+}
+
+// decodable Whether the node maps to a character or a prosign.
+func (n *treeNode) decodable() bool {
+	return n.char != 0 || n.prosign != ""
 }
 
 type DecoderConfig struct {
-	Wpm      int
+	// Wpm Speed expected, required.
+	Wpm int
+	// Tolerace Fraction of their ideal length elements and gaps may be off by, required.
 	Tolerace float64
+
+	// Adaptive When set, Wpm is only used as the initial speed estimate. The decoder then learns the dit length from the
+	// incoming detections and follows the sender as they speed up or slow down.
+	Adaptive bool
+
+	// EffectiveWpm Overall speed for Farnsworth timing. When set below Wpm, characters are expected at Wpm while the gaps
+	// between characters and words are stretched so the text comes out at EffectiveWpm.
+	EffectiveWpm int
+
+	// Prosigns Recognise run-together procedural signals (e.g. <SK>, <AR>). These take precedence over the punctuation
+	// sharing the same code, e.g. <AR> over '+'.
+	Prosigns bool
+	// RetractOnError When set along with Prosigns, the <HH> error prosign retracts the word being sent (or the previous
+	// word if none) by emitting one backspace per character instead of being printed.
+	RetractOnError bool
+
+	// Table Code table to decode with, defaults to ITU.
+	Table *CodeTable
+
+	// Corrector Optional stage re-ranking the interpretations of words with doubtful characters.
+	Corrector *Corrector
+
+	// Mode How to decode, defaults to International morse with a tree walk. American morse uses its own table and ignores
+	// Table, Prosigns and Adaptive.
+	Mode DecodeMode
+
+	// FlushTimeout How long Run keeps trying to hand over the last events once its context is done, defaults to 100ms.
+	FlushTimeout time.Duration
+}
+
+// Validate Whether a decoder can run with the config, it needs a speed and a tolerance to go by.
+func (c DecoderConfig) Validate() error {
+	if c.Wpm <= 0 {
+		return fmt.Errorf("decode: expecting a positive speed, got %d WPM", c.Wpm)
+	}
+	if c.Tolerace <= 0 {
+		return fmt.Errorf("decode: expecting a positive tolerance, got %g", c.Tolerace)
+	}
+	return nil
 }
 
+type DecodeMode int
+
+const (
+	ModeInternational DecodeMode = iota
+	ModeAmerican
+	// ModeViterbi International morse decoded probabilistically over a window of detections rather than committing to
+	// each element as it comes in. Better suited for weak or hand-sent signals.
+	ModeViterbi
+)
+
+const errorProsign = "HH"
+
+const defaultFlushTimeout = 100 * time.Millisecond
+
+// ErrEventsDropped Run gave up on events nobody was there to receive.
+var ErrEventsDropped = errors.New("decode: events dropped, nobody receiving")
+
+const (
+	// Bounds on the speed the adaptive tracker is allowed to settle on.
+	minAdaptiveWpm = 5
+	maxAdaptiveWpm = 60
+
+	// Number of recent marks (ON durations) used to estimate the dit length.
+	markHistorySize = 24
+	// Weight given to a new estimate when smoothing the dit length.
+	adaptiveSmoothing = 0.3
+)
+
 type MorseDecoder struct {
 	config DecoderConfig
 
+	// Guards the decoding state so the decoder can be reset while it runs.
+	stateMu sync.Mutex
+
 	root        *treeNode
 	currentNode *treeNode
 
+	// Active code table (can change through shift prosigns) and the trees built so far for each table.
+	table *CodeTable
+	trees map[*CodeTable]*treeNode
+
+	// Dit length estimate in milliseconds, guarded by mu since it can be read while decoding.
+	mu        sync.Mutex
+	ditLength float64
+	marks     []float64
+
+	// Elements received so far for the current character.
+	pattern string
+
+	// Confidence in the timing of the current character, from 0 (at the edge of tolerance) to 1 (exact).
+	confidence float64
+
+	// Time elapsed since decoding started and when the current character started and ended.
+	clock     time.Duration
+	charStart time.Duration
+	charEnd   time.Duration
+	// Whether anything was decoded since the last end of transmission.
+	active bool
+
+	// Detections not decoded yet in Viterbi mode, and the time the first one started.
+	window      []Detection
+	windowStart time.Duration
+	codes       map[*CodeTable][]viterbiCode
+
+	// Events of the word being held for the corrector.
+	wordEvents []Event
+
+	// Events waiting to be sent out.
+	events []Event
+
+	// What has been emitted for the current and previous words, in case they need to be retracted.
+	word     string
+	lastWord string
+
 	decodeIn   <-chan Detection
 	decodeOut  chan<- string
+	eventOut   chan<- Event
 	decodeStop <-chan struct{}
 }
 
@@ -33,16 +163,51 @@ type Detection struct {
 	Duration time.Duration
 }
 
+// NewMorseDecoder Create a decoder printing its output as text: characters, spaces between words, "|?|" for unknown
+// sequences and backspaces for retractions. Panics if the config doesn't validate.
 func NewMorseDecoder(in <-chan Detection, out chan<- string, done <-chan struct{}, cfg DecoderConfig) *MorseDecoder {
-	root := buildMorseDecodeTree()
+	decoder := newMorseDecoder(in, done, cfg)
+	decoder.decodeOut = out
+
+	return decoder
+}
+
+// NewMorseEventDecoder Create a decoder sending out structured events. Panics if the config doesn't validate.
+func NewMorseEventDecoder(in <-chan Detection, out chan<- Event, done <-chan struct{},
+	cfg DecoderConfig) *MorseDecoder {
+	decoder := newMorseDecoder(in, done, cfg)
+	decoder.eventOut = out
+
+	return decoder
+}
+
+// NewDecoder Create a decoder without any channels, to be driven synchronously through Decode and Flush. Panics if the
+// config doesn't validate, check it beforehand when it comes from the user.
+func NewDecoder(cfg DecoderConfig) *MorseDecoder {
+	return newMorseDecoder(nil, nil, cfg)
+}
+
+func newMorseDecoder(in <-chan Detection, done <-chan struct{}, cfg DecoderConfig) *MorseDecoder {
+	if err := cfg.Validate(); err != nil {
+		panic(err)
+	}
+	if cfg.Table == nil {
+		cfg.Table = ITU
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = defaultFlushTimeout
+	}
+
 	decoder := &MorseDecoder{
-		config:      cfg,
-		root:        root,
-		currentNode: root,
-		decodeIn:    in,
-		decodeOut:   out,
-		decodeStop:  done,
+		config:     cfg,
+		trees:      map[*CodeTable]*treeNode{},
+		codes:      map[*CodeTable][]viterbiCode{},
+		ditLength:  wpmToDitLength(float64(cfg.Wpm)),
+		decodeIn:   in,
+		decodeStop: done,
 	}
+	decoder.switchTable(cfg.Table)
+	decoder.currentNode = decoder.root
 
 	return decoder
 }
@@ -51,35 +216,255 @@ func NewMorseDecoder(in <-chan Detection, out chan<- string, done <-chan struct{
 //
 //	closed.
 func (md *MorseDecoder) StartDecode() {
-
-	// Reset
-	md.currentNode = md.root
+	md.stateMu.Lock()
+	md.reset()
+	md.stateMu.Unlock()
 
 	go func() {
+		var pending []Event
 		for {
 			select {
 			case in := <-md.decodeIn:
-				md.decode(in)
+				pending = md.publish(append(pending, md.Decode([]Detection{in})...), md.decodeStop)
 			case <-md.decodeStop:
-				if md.currentNode != md.root {
-					md.decodeOut <- string(md.currentNode.char)
+				// Nobody may be listening anymore, don't wait forever on the last events.
+				ctx, cancel := context.WithTimeout(context.Background(), md.config.FlushTimeout)
+				md.publish(append(pending, md.Flush()...), ctx.Done())
+				cancel()
+
+				if md.eventOut != nil {
+					close(md.eventOut)
+				} else {
+					close(md.decodeOut)
 				}
-				close(md.decodeOut)
 				return
 			}
 		}
 	}()
 }
 
+// Run Decode the detections from in, sending the events on out, until in is closed or the context is done. out is
+// closed on return.
+//
+// The character being received is always flushed. When in is closed, Run waits for the last events to be received
+// and returns nil. When the context is done, the last events are given FlushTimeout to be received and the context
+// error is returned, joined with ErrEventsDropped if some never were.
+func (md *MorseDecoder) Run(ctx context.Context, in <-chan Detection, out chan<- Event) error {
+	defer close(out)
+
+	for {
+		select {
+		case d, ok := <-in:
+			var pending []Event
+			if ok {
+				pending = md.Decode([]Detection{d})
+			} else {
+				pending = md.Flush()
+			}
+
+			if pending = send(out, pending, ctx.Done()); len(pending) > 0 {
+				return md.shutdown(ctx, out, pending)
+			}
+			if !ok {
+				return nil
+			}
+		case <-ctx.Done():
+			return md.shutdown(ctx, out, nil)
+		}
+	}
+}
+
+// shutdown Flush the decoder and give the consumer FlushTimeout to receive what is left.
+func (md *MorseDecoder) shutdown(ctx context.Context, out chan<- Event, pending []Event) error {
+	timeout, cancel := context.WithTimeout(context.Background(), md.config.FlushTimeout)
+	defer cancel()
+
+	if dropped := send(out, append(pending, md.Flush()...), timeout.Done()); len(dropped) > 0 {
+		return errors.Join(ctx.Err(), ErrEventsDropped)
+	}
+	return ctx.Err()
+}
+
+// send Send the events until abort is closed, returning those that could not be sent.
+func send(out chan<- Event, events []Event, abort <-chan struct{}) []Event {
+	for i, e := range events {
+		select {
+		case out <- e:
+		case <-abort:
+			return events[i:]
+		}
+	}
+	return nil
+}
+
+// Decode Synchronously decode the detections and return the events they produced. A character still being received
+// is held until the next detections or a call to Flush. Not to be used on a decoder started with StartDecode.
+func (md *MorseDecoder) Decode(detections []Detection) []Event {
+	md.stateMu.Lock()
+	defer md.stateMu.Unlock()
+
+	for _, d := range detections {
+		md.decode(d)
+	}
+
+	return md.take()
+}
+
+// Flush Synchronously end the transmission, returning the events for whatever was still being received.
+func (md *MorseDecoder) Flush() []Event {
+	md.stateMu.Lock()
+	defer md.stateMu.Unlock()
+
+	md.flush()
+
+	return md.take()
+}
+
+// Reset Drop whatever is being received and start over as a new decoder would, speed estimate included. Safe to call
+// while Run or StartDecode is going, e.g. when the signal being followed is changed.
+func (md *MorseDecoder) Reset() {
+	md.stateMu.Lock()
+	defer md.stateMu.Unlock()
+
+	md.reset()
+
+	md.mu.Lock()
+	md.ditLength = wpmToDitLength(float64(md.config.Wpm))
+	md.mu.Unlock()
+}
+
+// DecodeSeq Decode detections as they come from the sequence, yielding the events as soon as they are produced. The
+// transmission is flushed once the sequence is over.
+func (md *MorseDecoder) DecodeSeq(detections iter.Seq[Detection]) iter.Seq[Event] {
+	return func(yield func(Event) bool) {
+		for d := range detections {
+			for _, e := range md.Decode([]Detection{d}) {
+				if !yield(e) {
+					return
+				}
+			}
+		}
+
+		for _, e := range md.Flush() {
+			if !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// take Hand over the pending events.
+func (md *MorseDecoder) take() []Event {
+	events := slices.Clone(md.events)
+	md.events = md.events[:0]
+
+	return events
+}
+
+// reset Bring the decoder back to its initial state, keeping the speed estimate.
+func (md *MorseDecoder) reset() {
+	md.switchTable(md.config.Table)
+	md.currentNode = md.root
+	md.pattern = ""
+	md.clock = 0
+	md.window = md.window[:0]
+	md.active = false
+	md.events = md.events[:0]
+	md.wordEvents = md.wordEvents[:0]
+	md.marks = md.marks[:0]
+	md.word = ""
+	md.lastWord = ""
+}
+
+// publish Send the events out, either as they are or as text, until abort is closed. Returns the events that could not
+// be sent.
+func (md *MorseDecoder) publish(events []Event, abort <-chan struct{}) []Event {
+	if md.eventOut != nil {
+		return send(md.eventOut, events, abort)
+	}
+
+	for i, e := range events {
+		text := e.String()
+		if text == "" {
+			continue
+		}
+
+		select {
+		case md.decodeOut <- text:
+		case <-abort:
+			return events[i:]
+		}
+	}
+	return nil
+}
+
+// flush Emit whatever character was still being received and end the transmission.
+func (md *MorseDecoder) flush() {
+	md.charEnd = md.clock
+
+	switch md.config.Mode {
+	case ModeAmerican:
+		md.flushAmerican()
+	case ModeViterbi:
+		md.flushViterbi()
+	default:
+		if md.currentNode == nil || (md.currentNode != md.root && !md.currentNode.decodable()) {
+			md.unknown()
+		} else if md.currentNode != md.root {
+			md.output(false)
+		}
+		md.currentNode = md.root
+	}
+
+	md.endTransmission()
+}
+
 func (md *MorseDecoder) decode(d Detection) {
+	if d.State && md.pattern == "" {
+		md.charStart = md.clock
+		md.confidence = 1
+	}
+	md.charEnd = md.clock
+
+	switch md.config.Mode {
+	case ModeAmerican:
+		md.decodeAmerican(d)
+	case ModeViterbi:
+		md.decodeViterbi(d)
+	default:
+		md.decodeInternational(d)
+	}
+
+	md.clock += d.Duration
+}
+
+func (md *MorseDecoder) decodeInternational(d Detection) {
 	// If on, determine if dit or dah
 	if d.State {
+		if md.config.Adaptive {
+			md.trackSpeed(d.Duration)
+		}
+
+		isDit := md.approxDitLength(d.Duration)
+		isDah := !isDit && md.approxDahLength(d.Duration)
+		switch {
+		case isDit:
+			md.pattern += "."
+			md.rate(d.Duration, md.currentDitLength())
+		case isDah:
+			md.pattern += "-"
+			md.rate(d.Duration, 3*md.currentDitLength())
+		default:
+			md.pattern += "?"
+			md.confidence = 0
+		}
+
 		if md.currentNode == nil {
 			// Ignore, we're in error state.
 			return
 		}
 
-		if md.approxDitLength(d.Duration) {
+		if isDit {
 			if md.currentNode.left != nil {
 				// Dit, go left.
 				md.currentNode = md.currentNode.left
@@ -88,7 +473,7 @@ func (md *MorseDecoder) decode(d Detection) {
 				// Code doesn't exist. Put in error state.
 				md.currentNode = nil
 			}
-		} else if md.approxDahLength(d.Duration) {
+		} else if isDah {
 			if md.currentNode.right != nil {
 				// Dah, go right.
 				md.currentNode = md.currentNode.right
@@ -105,164 +490,391 @@ func (md *MorseDecoder) decode(d Detection) {
 
 		// If off, determine if space between dit and dah, or end character or end word.
 	} else {
-		// If we haven't decoded anything yet and didn't move from root, skip.
+		// If we haven't decoded anything yet and didn't move from root, skip. A long enough silence ends the transmission.
 		if md.currentNode == md.root {
+			if md.beyondWordLength(d.Duration) {
+				md.endTransmission()
+			}
 			return
 		}
 
 		if md.approxBetweenBeepLength(d.Duration) {
 			// Do nothing, wait for next dit or dah.
+			md.rate(d.Duration, md.currentDitLength())
 
 		} else {
 			// If we were in error state OR the sequence came up to an empty node in the tree, output the error and reset
 			// the decoder state.
-			if md.currentNode == nil || md.currentNode.char == 0 {
-				md.decodeOut <- "|?|"
+			if md.currentNode == nil || !md.currentNode.decodable() {
+				md.unknown()
 				md.currentNode = md.root
 				return
 			}
 
 			if md.approxBetweenCharLength(d.Duration) {
-				md.decodeOut <- string(md.currentNode.char)
+				md.rate(d.Duration, 3*md.spacingLength())
+				md.output(false)
 				md.currentNode = md.root
 
 			} else if md.approxBetweenWordLength(d.Duration) {
-				md.decodeOut <- fmt.Sprintf("%s ", string(md.currentNode.char))
+				md.rate(d.Duration, 7*md.spacingLength())
+				md.output(true)
 				md.currentNode = md.root
 
 			} else {
-				// End transmission? assume so...
-				md.decodeOut <- fmt.Sprintf("%s ", string(md.currentNode.char))
+				// End transmission? assume so... A gap too short for a word but too long for a character is dubious.
+				if !md.beyondWordLength(d.Duration) {
+					md.confidence = 0
+				}
+				md.output(true)
 				md.currentNode = md.root
+				if md.beyondWordLength(d.Duration) {
+					md.endTransmission()
+				}
 			}
 		}
 	}
 }
 
-func (md *MorseDecoder) approxDitLength(d time.Duration) bool {
-	ditLength := float64(60000) / float64(50*md.config.Wpm)
-	max := int64(ditLength + ditLength*md.config.Tolerace)
-	min := int64(ditLength - ditLength*md.config.Tolerace)
+// Wpm Current speed estimate, in words per minute. This is the configured speed unless adaptive tracking is enabled.
+func (md *MorseDecoder) Wpm() float64 {
+	md.mu.Lock()
+	defer md.mu.Unlock()
 
-	dm := d.Milliseconds()
-	return dm >= min && dm <= max
+	return ditLengthToWpm(md.ditLength)
 }
 
-func (md *MorseDecoder) approxDahLength(d time.Duration) bool {
-	ditLength := float64(60000) / float64(50*md.config.Wpm)
-	max := 3 * int64(ditLength+ditLength*md.config.Tolerace)
-	min := 3 * int64(ditLength-ditLength*md.config.Tolerace)
+// trackSpeed Re-estimate the dit length from the recent marks. The marks are split in two clusters (dits and dahs) and
+// the estimate is nudged towards what both clusters agree on.
+func (md *MorseDecoder) trackSpeed(d time.Duration) {
+	md.marks = append(md.marks, float64(d)/float64(time.Millisecond))
+	if len(md.marks) > markHistorySize {
+		md.marks = md.marks[1:]
+	}
 
-	dm := d.Milliseconds()
-	return dm >= min && dm <= max
+	md.mu.Lock()
+	defer md.mu.Unlock()
+
+	short, long, nShort, nLong := clusterMarks(md.marks)
+
+	var estimate float64
+	if nShort > 0 && nLong > 0 && long/short >= 2 {
+		// Both dits and dahs are present, weigh both to get the unit.
+		estimate = (short*float64(nShort) + long/3*float64(nLong)) / float64(nShort+nLong)
+	} else {
+		// A single cluster, decide if it's made of dits or dahs based on what we currently believe the unit to be.
+		center := (short*float64(nShort) + long*float64(nLong)) / float64(nShort+nLong)
+		if math.Abs(math.Log(center/md.ditLength)) <= math.Abs(math.Log(center/(3*md.ditLength))) {
+			estimate = center
+		} else {
+			estimate = center / 3
+		}
+	}
+
+	estimate = min(max(estimate, wpmToDitLength(maxAdaptiveWpm)), wpmToDitLength(minAdaptiveWpm))
+	md.ditLength += adaptiveSmoothing * (estimate - md.ditLength)
 }
 
-func (md *MorseDecoder) approxBetweenBeepLength(d time.Duration) bool {
-	ditLength := float64(60000) / float64(50*md.config.Wpm)
-	max := int64(ditLength + ditLength*md.config.Tolerace)
-	min := int64(ditLength - ditLength*md.config.Tolerace)
+// clusterMarks Split the marks in two clusters with a few rounds of k-means (k=2) in the log domain, which is well
+// suited for the 1:3 ratio between dits and dahs. Returns the cluster centers and sizes.
+func clusterMarks(marks []float64) (short, long float64, nShort, nLong int) {
+	short, long = marks[0], marks[0]
+	for _, m := range marks {
+		short = min(short, m)
+		long = max(long, m)
+	}
 
-	dm := d.Milliseconds()
-	return dm >= min && dm <= max
+	for range 5 {
+		var sumShort, sumLong float64
+		nShort, nLong = 0, 0
+		for _, m := range marks {
+			if math.Abs(math.Log(m/short)) <= math.Abs(math.Log(m/long)) {
+				sumShort += m
+				nShort++
+			} else {
+				sumLong += m
+				nLong++
+			}
+		}
+
+		if nShort > 0 {
+			short = sumShort / float64(nShort)
+		}
+		if nLong > 0 {
+			long = sumLong / float64(nLong)
+		}
+	}
+
+	return short, long, nShort, nLong
 }
 
-func (md *MorseDecoder) approxBetweenCharLength(d time.Duration) bool {
-	ditLength := float64(60000) / float64(50*md.config.Wpm)
-	max := 3 * int64(ditLength+ditLength*md.config.Tolerace)
-	min := 3 * int64(ditLength-ditLength*md.config.Tolerace)
+func wpmToDitLength(wpm float64) float64 {
+	return float64(60000) / (50 * wpm)
+}
+
+func ditLengthToWpm(ditLength float64) float64 {
+	return float64(60000) / (50 * ditLength)
+}
+
+// currentDitLength Dit length estimate, in milliseconds.
+func (md *MorseDecoder) currentDitLength() float64 {
+	md.mu.Lock()
+	defer md.mu.Unlock()
+
+	return md.ditLength
+}
 
-	dm := d.Milliseconds()
+// spacingLength Unit used for the gaps between characters and words, in milliseconds. Under Farnsworth timing, this
+// unit is stretched following the ARRL formula and keeps the same ratio to the dit length when the speed is being
+// tracked.
+func (md *MorseDecoder) spacingLength() float64 {
+	ditLength := md.currentDitLength()
+
+	charWpm := float64(md.config.Wpm)
+	return ditLength * farnsworthLength(charWpm, float64(md.config.EffectiveWpm)) / wpmToDitLength(charWpm)
+}
+
+// farnsworthLength Spacing unit (ms) for characters sent at charWpm to come out at effectiveWpm. Without an effective
+// speed below the character speed, this is the dit length.
+func farnsworthLength(charWpm, effectiveWpm float64) float64 {
+	if effectiveWpm <= 0 || effectiveWpm >= charWpm {
+		return wpmToDitLength(charWpm)
+	}
+
+	// Total delay added per word (ta) is spread over the 19 units of spacing contained in PARIS.
+	return 1000 * (60/effectiveWpm - 37.2/charWpm) / 19
+}
+
+// rate Lower the confidence in the character being received according to how far the duration is from the expected
+// length (ms). Exact timing keeps full confidence, timing at the edge of the tolerance brings it down to 0.
+func (md *MorseDecoder) rate(d time.Duration, expected float64) {
+	deviation := math.Abs(float64(d)/float64(time.Millisecond)-expected) / (expected * md.config.Tolerace)
+	md.confidence = min(md.confidence, max(0, 1-deviation))
+}
+
+// approxLength Check if the duration matches the expected length (ms), within tolerance.
+func (md *MorseDecoder) approxLength(d time.Duration, expected float64) bool {
+	max := expected + expected*md.config.Tolerace
+	min := expected - expected*md.config.Tolerace
+
+	dm := float64(d) / float64(time.Millisecond)
 	return dm >= min && dm <= max
 }
 
+// output Emit the character (or prosign) for the current node, followed by a space at the end of a word.
+func (md *MorseDecoder) output(wordEnd bool) {
+	if md.config.RetractOnError && md.currentNode.prosign == errorProsign {
+		md.retract()
+		return
+	}
+
+	kind := EventChar
+	if md.currentNode.prosign != "" {
+		kind = EventProsign
+	}
+	md.emit(kind, md.currentNode.symbol(), wordEnd)
+
+	if target, ok := md.table.Shifts[md.currentNode.prosign]; ok {
+		if target == nil {
+			target = md.config.Table
+		}
+		md.switchTable(target)
+	}
+}
+
+// emit Queue a decoded symbol, keeping track of the words sent in case they get retracted.
+func (md *MorseDecoder) emit(kind EventKind, symbol string, wordEnd bool) {
+	md.queue(kind, symbol)
+
+	md.word += symbol
+	if wordEnd {
+		md.lastWord = md.word
+		md.word = ""
+		md.queue(EventWordBreak, "")
+	}
+}
+
+// unknown Queue an unknown sequence, with what was received.
+func (md *MorseDecoder) unknown() {
+	md.queue(EventUnknown, "|?|")
+}
+
+// endTransmission Queue the end of a transmission, unless nothing was received since the last one.
+func (md *MorseDecoder) endTransmission() {
+	if md.active {
+		md.queue(EventEndOfTransmission, "")
+		md.active = false
+	}
+}
+
+// queue Add an event for the character being received and clear it. Events with no pattern (word breaks, end of
+// transmission) happen at the current time.
+func (md *MorseDecoder) queue(kind EventKind, text string) {
+	e := Event{
+		Kind:       kind,
+		Text:       text,
+		Pattern:    md.pattern,
+		Start:      md.charStart,
+		End:        md.charEnd,
+		Wpm:        md.Wpm(),
+		Confidence: md.confidence,
+	}
+	if md.pattern == "" {
+		e.Start = md.charEnd
+		if kind != EventWordBreak {
+			e.Confidence = 1
+		}
+	}
+
+	md.pattern = ""
+	md.active = kind != EventEndOfTransmission
+
+	// With a corrector, the characters of a word are held until the word is over.
+	if md.config.Corrector != nil {
+		if kind == EventChar || kind == EventProsign || kind == EventUnknown {
+			md.wordEvents = append(md.wordEvents, e)
+			return
+		}
+		md.correctWord()
+	}
+
+	md.events = append(md.events, e)
+}
+
+// correctWord Run the characters held for the word through the corrector and queue the result.
+func (md *MorseDecoder) correctWord() {
+	if len(md.wordEvents) == 0 {
+		return
+	}
+
+	corrected := md.config.Corrector.Correct(md.wordEvents)
+	md.events = append(md.events, corrected...)
+	md.wordEvents = md.wordEvents[:0]
+
+	// Retractions need to erase what was actually printed.
+	if md.word != "" {
+		md.word = Text(corrected)
+	} else {
+		md.lastWord = Text(corrected)
+	}
+}
+
+// switchTable Make the table the active one, building its tree the first time it's used.
+func (md *MorseDecoder) switchTable(table *CodeTable) {
+	root, ok := md.trees[table]
+	if !ok {
+		root = buildMorseDecodeTree(table, md.config.Prosigns)
+		md.trees[table] = root
+	}
+
+	md.table = table
+	md.root = root
+}
+
+// retract Erase the word being sent, or the previous one (and its trailing space) if nothing was sent since.
+func (md *MorseDecoder) retract() {
+	// Nothing was printed yet for the word being corrected, forget about it.
+	if md.config.Corrector != nil && md.word != "" {
+		md.wordEvents = md.wordEvents[:0]
+		md.word = ""
+		md.pattern = ""
+		return
+	}
+
+	erased := md.word
+	if erased == "" {
+		erased = md.lastWord + " "
+		md.lastWord = ""
+	}
+	md.word = ""
+
+	if erased != " " {
+		md.queue(EventRetract, erased)
+	} else {
+		md.pattern = ""
+	}
+}
+
+func (md *MorseDecoder) approxDitLength(d time.Duration) bool {
+	return md.approxLength(d, md.currentDitLength())
+}
+
+func (md *MorseDecoder) approxDahLength(d time.Duration) bool {
+	return md.approxLength(d, 3*md.currentDitLength())
+}
+
+func (md *MorseDecoder) approxBetweenBeepLength(d time.Duration) bool {
+	return md.approxLength(d, md.currentDitLength())
+}
+
+func (md *MorseDecoder) approxBetweenCharLength(d time.Duration) bool {
+	return md.approxLength(d, 3*md.spacingLength())
+}
+
 func (md *MorseDecoder) approxBetweenWordLength(d time.Duration) bool {
-	ditLength := float64(60000) / float64(50*md.config.Wpm)
-	max := 7 * int64(ditLength+ditLength*md.config.Tolerace)
-	min := 7 * int64(ditLength-ditLength*md.config.Tolerace)
+	return md.approxLength(d, 7*md.spacingLength())
+}
 
-	dm := d.Milliseconds()
-	return dm >= min && dm <= max
+// beyondWordLength Check if the duration is longer than any gap between words.
+func (md *MorseDecoder) beyondWordLength(d time.Duration) bool {
+	return float64(d)/float64(time.Millisecond) > 7*md.spacingLength()*(1+md.config.Tolerace)
 }
 
-func buildMorseDecodeTree() *treeNode {
-	var morseTable = map[byte]string{
-		'A':  ".-",
-		'B':  "-...",
-		'C':  "-.-.",
-		'D':  "-..",
-		'E':  ".",
-		'F':  "..-.",
-		'G':  "--.",
-		'H':  "....",
-		'I':  "..",
-		'J':  ".---",
-		'K':  "-.-",
-		'L':  ".-..",
-		'M':  "--",
-		'N':  "-.",
-		'O':  "---",
-		'P':  ".--.",
-		'Q':  "--.-",
-		'R':  ".-.",
-		'S':  "...",
-		'T':  "-",
-		'U':  "..-",
-		'V':  "...-",
-		'W':  ".--",
-		'X':  "-..-",
-		'Y':  "-.--",
-		'Z':  "--..",
-		'1':  ".----",
-		'2':  "..---",
-		'3':  "...--",
-		'4':  "....-",
-		'5':  ".....",
-		'6':  "-....",
-		'7':  "--...",
-		'8':  "---..",
-		'9':  "----.",
-		'0':  "-----",
-		'.':  ".-.-.-",
-		',':  "--..--",
-		'?':  "..--..",
-		'!':  "-.-.--",
-		':':  "---...",
-		'"':  ".-..-.",
-		'\'': ".----.",
-		'=':  "-...-",
-		'/':  "-..-.",
-		'(':  "-.--.",
-		')':  "-.--.-",
-		'&':  ".-...",
-		';':  "-.-.-.",
-		'+':  ".-.-.",
-		'-':  "-....-",
-		'_':  "..--.-",
-		'$':  "...-..-",
-		'@':  ".--.-.",
-	}
-
-	// Build the tree based on the morse table above.
+func buildMorseDecodeTree(table *CodeTable, withProsigns bool) *treeNode {
+	// Build the tree based on the code table.
 	root := &treeNode{}
-	for letter, code := range morseTable {
-		index := root
-		for i := range len(code) {
-			c := code[i]
-			switch c {
-			case '.':
-				if index.left == nil {
-					index.left = &treeNode{}
-				}
-				index = index.left
-			case '-':
-				if index.right == nil {
-					index.right = &treeNode{}
-				}
-				index = index.right
-			}
+	for letter, code := range table.Chars {
+		insertCode(root, code).char = letter
+	}
+
+	for prosign, code := range table.Prosigns {
+		if _, shift := table.Shifts[prosign]; withProsigns || shift {
+			insertCode(root, code).prosign = prosign
 		}
-		index.char = letter
+	}
+
+	// The error prosign is a run of eight or more dits, any extra dit stays on the same node.
+	if code, ok := table.Prosigns[errorProsign]; ok && withProsigns {
+		hh := insertCode(root, code)
+		hh.left = hh
 	}
 
 	return root
 }
+
+// insertCode Walk the tree following the code, creating nodes as needed, and return the node the code ends on.
+func insertCode(root *treeNode, code string) *treeNode {
+	index := root
+	for i := range len(code) {
+		c := code[i]
+		switch c {
+		case '.':
+			if index.left == nil {
+				index.left = &treeNode{}
+			}
+			index = index.left
+		case '-':
+			if index.right == nil {
+				index.right = &treeNode{}
+			}
+			index = index.right
+		}
+	}
+
+	return index
+}
+
+// lookupCode Walk the tree following the code and return the node it ends on, nil if there is none.
+func lookupCode(root *treeNode, code string) *treeNode {
+	index := root
+	for i := 0; i < len(code) && index != nil; i++ {
+		switch code[i] {
+		case '.':
+			index = index.left
+		case '-':
+			index = index.right
+		}
+	}
+
+	return index
+}
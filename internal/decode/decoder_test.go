@@ -1,238 +1,415 @@
 package decode
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"maps"
+	"math"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
 
-func Test_Decode(t *testing.T) {
-	ditLength := 48 * time.Millisecond
+type decodeTestCase struct {
+	name       string
+	detections []Detection
+	exp        string
+}
 
-	testCases := []struct {
-		name       string
-		detections []Detection
-		exp        string
-	}{
-		{
-			name: "single_character_E",
-			detections: []Detection{
-				{State: true, Duration: ditLength},
-				{State: false, Duration: 3 * ditLength},
-			},
-			exp: "E",
-		},
-		{
-			name: "single_character_T",
-			detections: []Detection{
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: 3 * ditLength},
-			},
-			exp: "T",
-		},
-		{
-			name: "single_character_A", // Sequence, start short.
-			detections: []Detection{
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: 3 * ditLength},
-			},
-			exp: "A",
-		},
-		{
-			name: "single_character_N", // Sequence, start long.
-			detections: []Detection{
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: 3 * ditLength},
-			},
-			exp: "N",
-		},
-		{
-			name: "single_character_empty_node",
-			detections: []Detection{
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: 3 * ditLength},
-			},
-			exp: "|?|",
-		},
-		{
-			name: "single_character_invalid_sequence",
-			detections: []Detection{
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: 3 * ditLength},
-			},
-			exp: "|?|",
-		},
-		{
-			name: "single_character_?",
-			detections: []Detection{
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: 3 * ditLength},
-			},
-			exp: "?",
-		},
-		{
-			name: "full_word_SOS",
-			detections: []Detection{
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: 3 * ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: 3 * ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: 3 * ditLength},
-			},
-			exp: "SOS",
-		},
-		{
-			name: "two_full_words_SOS SOS",
-			detections: []Detection{
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: 3 * ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: 3 * ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: 7 * ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: 3 * ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: 3 * ditLength},
-				{State: false, Duration: 3 * ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: ditLength},
-				{State: true, Duration: ditLength},
-				{State: false, Duration: 3 * ditLength},
-			},
-			exp: "SOS SOS",
-		},
-		{
-			name: "single_character_off_timing_positive_U",
-			detections: []Detection{
-				{State: true, Duration: time.Duration(float64(ditLength) * 1.2)},
-				{State: false, Duration: time.Duration(float64(ditLength) * 1.2)},
-				{State: true, Duration: time.Duration(float64(ditLength) * 1.2)},
-				{State: false, Duration: time.Duration(float64(ditLength) * 1.2)},
-				{State: true, Duration: time.Duration(float64(3*ditLength) * 1.2)},
-				{State: false, Duration: time.Duration(float64(3*ditLength) * 1.2)},
-			},
-			exp: "U",
-		},
+// decodeTestCases Cases shared by the channel and the synchronous decoding tests, sent at 25 WPM.
+func decodeTestCases(t *testing.T) []decodeTestCase {
+	config := EncoderConfig{Wpm: 25}
+
+	return []decodeTestCase{
+		{name: "single_character_E", detections: encode(t, config, "E"), exp: "E"},
+		{name: "single_character_T", detections: encode(t, config, "T"), exp: "T"},
+		// Sequence, start short.
+		{name: "single_character_A", detections: encode(t, config, "A"), exp: "A"},
+		// Sequence, start long.
+		{name: "single_character_N", detections: encode(t, config, "N"), exp: "N"},
 		{
-			name: "single_character_off_timing_negative_U",
-			detections: []Detection{
-				{State: true, Duration: time.Duration(float64(ditLength) * 0.8)},
-				{State: false, Duration: time.Duration(float64(ditLength) * 0.8)},
-				{State: true, Duration: time.Duration(float64(ditLength) * 0.8)},
-				{State: false, Duration: time.Duration(float64(ditLength) * 0.8)},
-				{State: true, Duration: time.Duration(float64(3*ditLength) * 0.8)},
-				{State: false, Duration: time.Duration(float64(3*ditLength) * 0.8)},
-			},
-			exp: "U",
+			name:       "single_character_empty_node",
+			detections: encode(t, EncoderConfig{Wpm: 25, Table: undecodable}, "#"),
+			exp:        "|?|",
 		},
+		// Without prosigns, HH runs off the tree.
+		{name: "single_character_invalid_sequence", detections: encode(t, config, "<HH>"), exp: "|?|"},
+		{name: "single_character_?", detections: encode(t, config, "?"), exp: "?"},
+		{name: "full_word_SOS", detections: encode(t, config, "SOS"), exp: "SOS"},
+		{name: "two_full_words_SOS SOS", detections: encode(t, config, "SOS SOS"), exp: "SOS SOS"},
+		{name: "single_character_off_timing_positive_U", detections: stretch(encode(t, config, "U"), 1.2), exp: "U"},
+		{name: "single_character_off_timing_negative_U", detections: stretch(encode(t, config, "U"), 0.8), exp: "U"},
+	}
+}
+
+// undecodable ITU along with codes the decoder has nothing for, to send them anyway: # (.-.-) and % (......).
+var undecodable = &CodeTable{
+	Name: "undecodable",
+	Chars: func() map[rune]string {
+		chars := maps.Clone(ITU.Chars)
+		chars['#'], chars['%'] = ".-.-", "......"
+		return chars
+	}(),
+	Prosigns: ITU.Prosigns,
+}
+
+// encode Detections for the text, as sent by an encoder with the config.
+func encode(t *testing.T, config EncoderConfig, text string) []Detection {
+	t.Helper()
+
+	detections, err := NewEncoder(config).Encode(text)
+	if err != nil {
+		t.Fatalf("expecting no error encoding [%s], got %v", text, err)
+	}
+	return detections
+}
+
+// stretch Detections all lengthened, or shortened, by the factor.
+func stretch(detections []Detection, factor float64) []Detection {
+	stretched := make([]Detection, len(detections))
+	for i, d := range detections {
+		stretched[i] = Detection{State: d.State, Duration: time.Duration(float64(d.Duration) * factor)}
 	}
+	return stretched
+}
 
+func Test_Decode(t *testing.T) {
 	config := DecoderConfig{
 		Wpm:      25,
 		Tolerace: 0.4,
 	}
 
-	for _, tc := range testCases {
+	for _, tc := range decodeTestCases(t) {
 		t.Run(tc.name, func(t *testing.T) {
-			decodeOut := make(chan string)
-			decodeIn := make(chan Detection)
-			done := make(chan struct{})
-			defer close(decodeIn)
-			defer close(done)
+			if _, output := runDecoder(config, tc.detections); output != tc.exp {
+				t.Errorf("expecting [%s], got [%s]", tc.exp, output)
+			}
+		})
+	}
+}
 
-			decoder := NewMorseDecoder(decodeIn, decodeOut, done, config)
-			decoder.StartDecode()
+func Test_DecodeSync(t *testing.T) {
+	config := DecoderConfig{
+		Wpm:      25,
+		Tolerace: 0.4,
+	}
+
+	for _, tc := range decodeTestCases(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			decoder := NewDecoder(config)
+
+			events := decoder.Decode(tc.detections)
+			events = append(events, decoder.Flush()...)
+
+			if output := Text(events); output != tc.exp {
+				t.Errorf("expecting [%s], got [%s]", tc.exp, output)
+			}
+		})
+	}
+}
+
+func Test_DecodeSeq(t *testing.T) {
+	config := DecoderConfig{
+		Wpm:      25,
+		Tolerace: 0.4,
+	}
+
+	for _, tc := range decodeTestCases(t) {
+		t.Run(tc.name, func(t *testing.T) {
+			decoder := NewDecoder(config)
 
 			output := ""
-			go func() {
-				for {
-					select {
-					case msg := <-decodeOut:
-						output = output + msg
-					case <-done:
-						return
-					}
+			for e := range decoder.DecodeSeq(slices.Values(tc.detections)) {
+				output += e.String()
+			}
+
+			if output != tc.exp {
+				t.Errorf("expecting [%s], got [%s]", tc.exp, output)
+			}
+		})
+	}
+}
+
+func Test_DecoderConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config DecoderConfig
+		valid  bool
+	}{
+		{name: "valid", config: DecoderConfig{Wpm: 25, Tolerace: 0.4}, valid: true},
+		{name: "no_speed", config: DecoderConfig{Tolerace: 0.4}},
+		{name: "no_tolerance", config: DecoderConfig{Wpm: 25}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.config.Validate(); (err == nil) != tc.valid {
+				t.Errorf("expecting valid to be %v, got %v", tc.valid, err)
+			}
+
+			defer func() {
+				if recovered := recover(); (recovered == nil) != tc.valid {
+					t.Errorf("expecting a panic to be %v, got %v", !tc.valid, recovered)
 				}
 			}()
+			NewDecoder(tc.config)
+		})
+	}
+}
+
+func Test_DecodeSyncHoldsPartialCharacter(t *testing.T) {
+	decoder := NewDecoder(DecoderConfig{Wpm: 25, Tolerace: 0.4})
+	ditLength := 48 * time.Millisecond
+
+	// The dah isn't followed by a gap yet, nothing can come out.
+	events := decoder.Decode([]Detection{
+		{State: true, Duration: ditLength},
+		{State: false, Duration: ditLength},
+		{State: true, Duration: 3 * ditLength},
+	})
+	if len(events) != 0 {
+		t.Errorf("expecting no events, got %#v", events)
+	}
+
+	if output := Text(decoder.Flush()); output != "A" {
+		t.Errorf("expecting [A], got [%s]", output)
+	}
+	if events := decoder.Flush(); len(events) != 0 {
+		t.Errorf("expecting nothing more after flushing, got %#v", events)
+	}
+}
+
+func Test_Run(t *testing.T) {
+	decoder := NewDecoder(DecoderConfig{Wpm: 25, Tolerace: 0.4})
+
+	in := make(chan Detection)
+	out := make(chan Event)
+	result := make(chan error)
+	go func() { result <- decoder.Run(context.Background(), in, out) }()
 
-			for _, detection := range tc.detections {
-				decodeIn <- detection
+	// The last character isn't followed by a gap, closing the input has to flush it.
+	detections := encode(t, EncoderConfig{Wpm: 25}, "SOS")
+	go func() {
+		for _, d := range detections[:len(detections)-1] {
+			in <- d
+		}
+		close(in)
+	}()
+
+	events := []Event{}
+	for e := range out {
+		events = append(events, e)
+	}
+
+	if err := <-result; err != nil {
+		t.Errorf("expecting no error, got %v", err)
+	}
+	if output := Text(events); output != "SOS" {
+		t.Errorf("expecting [SOS], got [%s]", output)
+	}
+	if events[len(events)-1].Kind != EventEndOfTransmission {
+		t.Errorf("expecting the transmission to end, got %#v", events[len(events)-1])
+	}
+}
+
+func Test_RunCancelled(t *testing.T) {
+	ditLength := 48 * time.Millisecond
+
+	testCases := []struct {
+		name    string
+		receive bool
+		dropped bool
+	}{
+		{name: "consumer_receiving", receive: true},
+		{name: "consumer_gone", dropped: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decoder := NewDecoder(DecoderConfig{Wpm: 25, Tolerace: 0.4, FlushTimeout: 20 * time.Millisecond})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			in := make(chan Detection)
+			out := make(chan Event)
+			result := make(chan error)
+			go func() { result <- decoder.Run(ctx, in, out) }()
+
+			// Half a character, with nobody reading it can't go anywhere.
+			in <- Detection{State: true, Duration: ditLength}
+			in <- Detection{State: false, Duration: ditLength}
+			in <- Detection{State: true, Duration: 3 * ditLength}
+			cancel()
+
+			output := ""
+			if tc.receive {
+				for e := range out {
+					output += e.String()
+				}
 			}
-			time.Sleep(5 * time.Millisecond)
 
-			if output != tc.exp {
-				fmt.Println([]byte(output))
-				fmt.Println(len(output))
-				t.Errorf("expecting [%s], got [%s]", tc.exp, output)
+			select {
+			case err := <-result:
+				if !errors.Is(err, context.Canceled) {
+					t.Errorf("expecting the context error, got %v", err)
+				}
+				if dropped := errors.Is(err, ErrEventsDropped); dropped != tc.dropped {
+					t.Errorf("expecting events dropped to be %v, got %v", tc.dropped, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Run didn't return")
+			}
+
+			if tc.receive && output != "A" {
+				t.Errorf("expecting [A], got [%s]", output)
+			}
+		})
+	}
+}
+
+func Test_Reset(t *testing.T) {
+	decoder := NewDecoder(DecoderConfig{Wpm: 25, Tolerace: 0.4, Adaptive: true})
+
+	// Slow down to 15 WPM, and leave a character hanging.
+	detections := encode(t, EncoderConfig{Wpm: 15}, "PARIS PARIS")
+	decoder.Decode(append(detections, Detection{State: true, Duration: 80 * time.Millisecond}))
+
+	decoder.Reset()
+	if wpm := decoder.Wpm(); wpm != 25 {
+		t.Errorf("expecting the speed back to 25 WPM, got %.1f", wpm)
+	}
+	if events := decoder.Flush(); len(events) != 0 {
+		t.Errorf("expecting nothing left after a reset, got %#v", events)
+	}
+
+	events := decoder.Decode(encode(t, EncoderConfig{Wpm: 25}, "TEST"))
+	if output := Text(events); output != "TEST" {
+		t.Errorf("expecting [TEST], got [%s]", output)
+	}
+	if events[0].Start != 0 {
+		t.Errorf("expecting the clock to start over, got %v", events[0].Start)
+	}
+}
+
+// runDecoder Feed all detections to a new decoder, stop it and return everything it printed.
+func runDecoder(config DecoderConfig, detections []Detection) (*MorseDecoder, string) {
+	decodeOut := make(chan string)
+	decodeIn := make(chan Detection)
+	done := make(chan struct{})
+
+	decoder := NewMorseDecoder(decodeIn, decodeOut, done, config)
+	decoder.StartDecode()
+
+	output := make(chan string)
+	go func() {
+		text := ""
+		for msg := range decodeOut {
+			text = text + msg
+		}
+		output <- text
+	}()
+
+	for _, detection := range detections {
+		decodeIn <- detection
+	}
+	close(done)
+
+	return decoder, <-output
+}
+
+func Test_AdaptiveWpm(t *testing.T) {
+
+	testCases := []struct {
+		name string
+		wpm  int
+	}{
+		{name: "slower_18_wpm", wpm: 18},
+		{name: "faster_35_wpm", wpm: 35},
+		{name: "same_25_wpm", wpm: 25},
+	}
+
+	config := DecoderConfig{
+		Wpm:      25,
+		Tolerace: 0.4,
+		Adaptive: true,
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			detections, err := NewEncoder(EncoderConfig{Wpm: tc.wpm}).Encode(strings.Repeat("PARIS ", 8))
+			if err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+
+			decoder, text := runDecoder(config, detections)
+
+			if math.Abs(decoder.Wpm()-float64(tc.wpm)) > 1 {
+				t.Errorf("expecting speed estimate around [%d], got [%.2f]", tc.wpm, decoder.Wpm())
+			}
+
+			// Give the tracker a couple of words to lock on.
+			if !strings.HasSuffix(text, "PARIS PARIS PARIS ") {
+				t.Errorf("expecting output to end with [PARIS PARIS PARIS ], got [%s]", text)
+			}
+		})
+	}
+}
+
+func Test_Farnsworth(t *testing.T) {
+	// Characters sent at 18 WPM, spaced out for an effective 5 WPM.
+	detections := encode(t, EncoderConfig{Wpm: 18, EffectiveWpm: 5}, "CQ DE W1AW ")
+
+	testCases := []struct {
+		name         string
+		effectiveWpm int
+		exp          string
+	}{
+		{name: "farnsworth_timing", effectiveWpm: 5, exp: "CQ DE W1AW "},
+		{name: "standard_timing_misreads_gaps", effectiveWpm: 0, exp: "C Q D E W 1 A W "},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := DecoderConfig{
+				Wpm:          18,
+				Tolerace:     0.4,
+				EffectiveWpm: tc.effectiveWpm,
+			}
+
+			if _, text := runDecoder(config, detections); text != tc.exp {
+				t.Errorf("expecting [%s], got [%s]", tc.exp, text)
+			}
+		})
+	}
+}
+
+func Test_Prosigns(t *testing.T) {
+	testCases := []struct {
+		name    string
+		text    string
+		retract bool
+		exp     string
+	}{
+		{name: "end_of_contact_SK", text: "CQ <SK>", exp: "CQ <SK>"},
+		{name: "AR_over_plus", text: "R 2 <AR>", exp: "R 2 <AR>"},
+		{name: "KN_over_parenthesis", text: "BK <KN>", exp: "BK <KN>"},
+		{name: "distress_SOS", text: "<SOS> <SOS>", exp: "<SOS> <SOS>"},
+		{name: "error_HH_printed", text: "TESR<HH>TEST", exp: "TESR<HH>TEST"},
+		{name: "error_HH_retracts_current_word", text: "TESR<HH>TEST", retract: true, exp: "TESR\b\b\b\bTEST"},
+		{name: "error_HH_retracts_previous_word", text: "CQ TESR <HH> TEST", retract: true, exp: "CQ TESR \b\b\b\b\bTEST"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := DecoderConfig{
+				Wpm:            25,
+				Tolerace:       0.4,
+				Prosigns:       true,
+				RetractOnError: tc.retract,
+			}
+
+			if _, text := runDecoder(config, encode(t, EncoderConfig{Wpm: 25}, tc.text)); text != tc.exp {
+				t.Errorf("expecting [%q], got [%q]", tc.exp, text)
 			}
 		})
 	}
@@ -0,0 +1,234 @@
+package decode
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+	"unicode"
+)
+
+const (
+	// Standard weight, marks and spaces inside a character are the same length.
+	defaultWeight = 50
+
+	// Shortest a jittered element is allowed to get, as a fraction of its ideal length.
+	minJitterFactor = 0.1
+)
+
+// EncoderConfig Timing of the generated morse.
+type EncoderConfig struct {
+	// Wpm Character speed, required.
+	Wpm int
+
+	// EffectiveWpm Overall speed for Farnsworth timing, the gaps between characters and words are stretched for the text
+	// to come out at this speed. Ignored when not below Wpm.
+	EffectiveWpm int
+
+	// Weight Keyer weight in percent, defaults to 50 (standard). Above 50, marks get longer and the spaces following them
+	// shorter by the same amount, so the speed doesn't change. Below 50, the other way around. Has to stay under 100,
+	// where the spaces are gone.
+	Weight int
+
+	// Jitter Random deviation of each element and gap, as a fraction of its length (standard deviation). Makes for hand
+	// sent sounding morse.
+	Jitter float64
+	// Seed Seed for the jitter, the same seed gives the same sequence.
+	Seed uint64
+
+	// Table Code table to encode with, defaults to ITU. The American table is sent with its own element lengths.
+	Table *CodeTable
+}
+
+// Encoder Turns text into detections, the way a keyer would send it.
+type Encoder struct {
+	config EncoderConfig
+	rand   *rand.Rand
+
+	// Active code table, shift prosigns are sent as needed to reach characters of other tables.
+	table *CodeTable
+
+	detections []Detection
+}
+
+// NewEncoder Create an encoder.
+func NewEncoder(cfg EncoderConfig) *Encoder {
+	if cfg.Table == nil {
+		cfg.Table = ITU
+	}
+	if cfg.Weight == 0 {
+		cfg.Weight = defaultWeight
+	}
+
+	return &Encoder{
+		config: cfg,
+		rand:   rand.New(rand.NewPCG(cfg.Seed, cfg.Seed)),
+	}
+}
+
+// Encode Turn the text into alternating mark and gap detections, starting with a mark and ending with the gap after the
+// last character (a word gap if the text ends with white space). Letters are case insensitive, white space separates
+// words and prosigns are written in angle brackets (e.g. <SK>), as the decoder emits them. Each call starts over from
+// the configured table, shift prosigns switch tables whether written out or sent for a character.
+func (e *Encoder) Encode(text string) ([]Detection, error) {
+	if e.config.Wpm <= 0 {
+		return nil, fmt.Errorf("encode: expecting a positive speed, got %d WPM", e.config.Wpm)
+	}
+	// At 100 and over, the spaces inside characters are gone.
+	if e.config.Weight < 0 || e.config.Weight >= 100 {
+		return nil, fmt.Errorf("encode: expecting a weight between 0 and 100, got %d", e.config.Weight)
+	}
+
+	e.table = e.config.Table
+	e.detections = []Detection{}
+
+	ditLength := wpmToDitLength(float64(e.config.Wpm))
+	spacingLength := farnsworthLength(float64(e.config.Wpm), float64(e.config.EffectiveWpm))
+
+	charGap, wordGap := 3*spacingLength, 7*spacingLength
+	if e.config.Table == American {
+		charGap, wordGap = 3*ditLength, 6*ditLength
+	}
+
+	gap := 0.0
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if unicode.IsSpace(r) {
+			if len(e.detections) > 0 {
+				gap = wordGap
+			}
+			continue
+		}
+
+		var codes []string
+		if r == '<' {
+			end := slices.Index(runes[i:], '>')
+			if end < 0 {
+				return nil, fmt.Errorf("encode: unterminated prosign at %d", i)
+			}
+
+			prosign := string(runes[i+1 : i+end])
+			code, ok := e.table.Prosigns[prosign]
+			if !ok {
+				return nil, fmt.Errorf("encode: no prosign <%s> in the %s table", prosign, e.table.Name)
+			}
+			codes = []string{code}
+			i += end
+
+			// Sent by hand, a shift prosign switches tables as it would when sent for a character.
+			if target, ok := e.table.Shifts[prosign]; ok {
+				if target == nil {
+					target = e.config.Table
+				}
+				e.table = target
+			}
+		} else {
+			// Tables holding lower case characters get them as they are.
+			if _, ok := e.table.Chars[r]; !ok {
+				r = unicode.ToUpper(r)
+			}
+
+			var err error
+			if codes, err = e.codes(r); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, code := range codes {
+			if len(e.detections) > 0 {
+				e.gap(max(gap, charGap))
+			}
+			e.character(code, ditLength)
+			gap = 0
+		}
+	}
+
+	if len(e.detections) > 0 {
+		e.gap(max(gap, charGap))
+	}
+
+	return e.detections, nil
+}
+
+// codes Codes to send for the character, led by a shift prosign if it is only found in a table the active one shifts
+// to.
+func (e *Encoder) codes(r rune) ([]string, error) {
+	if code, ok := e.table.Chars[r]; ok {
+		return []string{code}, nil
+	}
+
+	// Go through the shifts in order, map iteration isn't stable.
+	shifts := make([]string, 0, len(e.table.Shifts))
+	for prosign := range e.table.Shifts {
+		shifts = append(shifts, prosign)
+	}
+	slices.Sort(shifts)
+
+	for _, prosign := range shifts {
+		target := e.table.Shifts[prosign]
+		if target == nil {
+			target = e.config.Table
+		}
+
+		if code, ok := target.Chars[r]; ok {
+			shift := e.table.Prosigns[prosign]
+			e.table = target
+			return []string{shift, code}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("encode: no %q in the %s table", r, e.table.Name)
+}
+
+// character Send the elements of a code, with element spaces between them.
+func (e *Encoder) character(code string, ditLength float64) {
+	for i := range len(code) {
+		// The intra-character space of American morse stands in for the element space.
+		if i > 0 && code[i] != americanSpace && code[i-1] != americanSpace {
+			e.gap(ditLength)
+		}
+
+		switch code[i] {
+		case '.':
+			e.mark(ditLength)
+		case '-':
+			if e.config.Table == American {
+				e.mark(2 * ditLength)
+			} else {
+				e.mark(3 * ditLength)
+			}
+		case americanLongDash:
+			e.mark(4 * ditLength)
+		case americanExtraLongDash:
+			e.mark(6 * ditLength)
+		case americanSpace:
+			e.gap(2 * ditLength)
+		}
+	}
+}
+
+// mark Append a mark (ms), lengthened according to the weight.
+func (e *Encoder) mark(length float64) {
+	e.append(true, length+e.weighting())
+}
+
+// gap Append a gap (ms), shortened according to the weight.
+func (e *Encoder) gap(length float64) {
+	e.append(false, length-e.weighting())
+}
+
+// weighting Length (ms) taken from spaces and given to marks.
+func (e *Encoder) weighting() float64 {
+	return float64(e.config.Weight-defaultWeight) / defaultWeight * wpmToDitLength(float64(e.config.Wpm))
+}
+
+func (e *Encoder) append(state bool, length float64) {
+	if e.config.Jitter > 0 {
+		length *= max(minJitterFactor, 1+e.rand.NormFloat64()*e.config.Jitter)
+	}
+	length = max(length, 0)
+
+	duration := time.Duration(length * float64(time.Millisecond))
+	e.detections = append(e.detections, Detection{State: state, Duration: duration})
+}
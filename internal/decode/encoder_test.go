@@ -0,0 +1,183 @@
+package decode
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Encode(t *testing.T) {
+	ms := time.Millisecond
+
+	testCases := []struct {
+		name   string
+		config EncoderConfig
+		text   string
+		exp    []Detection
+	}{
+		{
+			name:   "standard_timing",
+			config: EncoderConfig{Wpm: 25},
+			text:   "a e",
+			exp: []Detection{
+				{State: true, Duration: 48 * ms},
+				{State: false, Duration: 48 * ms},
+				{State: true, Duration: 144 * ms},
+				{State: false, Duration: 336 * ms},
+				{State: true, Duration: 48 * ms},
+				{State: false, Duration: 144 * ms},
+			},
+		},
+		{
+			name:   "weighted",
+			config: EncoderConfig{Wpm: 25, Weight: 75},
+			text:   "A",
+			exp: []Detection{
+				{State: true, Duration: 72 * ms},
+				{State: false, Duration: 24 * ms},
+				{State: true, Duration: 168 * ms},
+				{State: false, Duration: 120 * ms},
+			},
+		},
+		{
+			name:   "farnsworth",
+			config: EncoderConfig{Wpm: 20, EffectiveWpm: 10},
+			text:   "E E",
+			exp: []Detection{
+				{State: true, Duration: 60 * ms},
+				{State: false, Duration: 1525 * ms}, // 7 units of (60/10 - 37.2/20) / 19 s
+				{State: true, Duration: 60 * ms},
+				{State: false, Duration: 654 * ms},
+			},
+		},
+		{
+			name:   "american_spaced_character",
+			config: EncoderConfig{Wpm: 25, Table: American},
+			text:   "C",
+			exp: []Detection{
+				{State: true, Duration: 48 * ms},
+				{State: false, Duration: 48 * ms},
+				{State: true, Duration: 48 * ms},
+				{State: false, Duration: 96 * ms},
+				{State: true, Duration: 48 * ms},
+				{State: false, Duration: 144 * ms},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			detections, err := NewEncoder(tc.config).Encode(tc.text)
+			if err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+
+			if len(detections) != len(tc.exp) {
+				t.Fatalf("expecting %v, got %v", tc.exp, detections)
+			}
+			for i, d := range detections {
+				if d.State != tc.exp[i].State || (d.Duration-tc.exp[i].Duration).Abs() > time.Millisecond {
+					t.Errorf("expecting %v at %d, got %v", tc.exp[i], i, d)
+				}
+			}
+		})
+	}
+}
+
+func Test_EncodeErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		wpm    int
+		weight int
+		text   string
+	}{
+		{name: "not_in_table", wpm: 25, text: "CQ #"},
+		{name: "unknown_prosign", wpm: 25, text: "<XX>"},
+		{name: "unterminated_prosign", wpm: 25, text: "<SK"},
+		{name: "no_speed", wpm: 0, text: "CQ"},
+		{name: "no_spaces", wpm: 25, weight: 100, text: "CQ"},
+		{name: "negative_weight", wpm: 25, weight: -10, text: "CQ"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewEncoder(EncoderConfig{Wpm: tc.wpm, Weight: tc.weight}).Encode(tc.text); err == nil {
+				t.Errorf("expecting an error encoding [%s]", tc.text)
+			}
+		})
+	}
+}
+
+func Test_EncodeDecode(t *testing.T) {
+	testCases := []struct {
+		name    string
+		encoder EncoderConfig
+		decoder DecoderConfig
+		text    string
+		exp     string
+	}{
+		{
+			name:    "plain_text",
+			encoder: EncoderConfig{Wpm: 25},
+			decoder: DecoderConfig{Wpm: 25, Tolerace: 0.4},
+			text:    "cq de w1aw",
+			exp:     "CQ DE W1AW",
+		},
+		{
+			name:    "prosigns",
+			encoder: EncoderConfig{Wpm: 25},
+			decoder: DecoderConfig{Wpm: 25, Tolerace: 0.4, Prosigns: true},
+			text:    "73 <SK>",
+			exp:     "73 <SK>",
+		},
+		{
+			name:    "farnsworth",
+			encoder: EncoderConfig{Wpm: 18, EffectiveWpm: 5},
+			decoder: DecoderConfig{Wpm: 18, Tolerace: 0.4, EffectiveWpm: 5},
+			text:    "QRS PSE",
+			exp:     "QRS PSE",
+		},
+		{
+			name:    "heavy_weight",
+			encoder: EncoderConfig{Wpm: 25, Weight: 60},
+			decoder: DecoderConfig{Wpm: 25, Tolerace: 0.4},
+			text:    "PARIS",
+			exp:     "PARIS",
+		},
+		{
+			name:    "jitter",
+			encoder: EncoderConfig{Wpm: 25, Jitter: 0.05, Seed: 7},
+			decoder: DecoderConfig{Wpm: 25, Tolerace: 0.4},
+			text:    "THE QUICK BROWN FOX",
+			exp:     "THE QUICK BROWN FOX",
+		},
+		{
+			name:    "shift_to_wabun_and_back",
+			encoder: EncoderConfig{Wpm: 25, Table: Japanese},
+			decoder: DecoderConfig{Wpm: 25, Tolerace: 0.4, Table: Japanese},
+			text:    "DE JA1 イロハ K",
+			exp:     "DE JA1 <DO>イロハ <SN>K",
+		},
+		{
+			name:    "american",
+			encoder: EncoderConfig{Wpm: 20, Table: American},
+			decoder: DecoderConfig{Wpm: 20, Tolerace: 0.3, Mode: ModeAmerican},
+			text:    "OCEAN 10",
+			exp:     "OCEAN 10",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			detections, err := NewEncoder(tc.encoder).Encode(tc.text)
+			if err != nil {
+				t.Fatalf("expecting no error, got %v", err)
+			}
+
+			decoder := NewDecoder(tc.decoder)
+			events := append(decoder.Decode(detections), decoder.Flush()...)
+			if output := Text(events); output != tc.exp {
+				t.Errorf("expecting [%s], got [%s]", tc.exp, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package decode
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type EventKind int
+
+const (
+	// EventChar A decoded character.
+	EventChar EventKind = iota
+	// EventWordBreak The gap after a character was long enough to end the word.
+	EventWordBreak
+	// EventProsign A decoded prosign, Text holds it in angle brackets (e.g. <SK>).
+	EventProsign
+	// EventUnknown A sequence that doesn't map to anything in the code table, Pattern holds what was received.
+	EventUnknown
+	// EventRetract The <HH> error prosign was received, Text holds what should be erased.
+	EventRetract
+	// EventEndOfTransmission The sender went silent for longer than a word gap, or decoding was stopped.
+	EventEndOfTransmission
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventChar:
+		return "char"
+	case EventWordBreak:
+		return "word-break"
+	case EventProsign:
+		return "prosign"
+	case EventUnknown:
+		return "unknown"
+	case EventRetract:
+		return "retract"
+	case EventEndOfTransmission:
+		return "end-of-transmission"
+	}
+	return "invalid"
+}
+
+// Event Something that happened while decoding.
+type Event struct {
+	Kind EventKind
+	Text string
+
+	// Pattern Dits and dahs received for the event, '?' marks an element that could not be interpreted.
+	Pattern string
+
+	// Start and End Offsets from the start of decoding, from the first element of the character to the beginning of the
+	// gap that ended it.
+	Start time.Duration
+	End   time.Duration
+
+	// Wpm Speed estimate when the event was emitted.
+	Wpm float64
+
+	// Confidence How closely the elements and gaps of the character matched the ideal timing, from 0 (every element within
+	// tolerance, but some at its edge) to 1 (exact). Word breaks carry the confidence of the character they follow,
+	// over its elements and gaps up to the word gap.
+	Confidence float64
+}
+
+// String Text form of the event, as sent on the string channel. Retractions are rendered as backspaces and the end of a
+// transmission renders as nothing.
+func (e Event) String() string {
+	switch e.Kind {
+	case EventWordBreak:
+		return " "
+	case EventRetract:
+		return strings.Repeat("\b", utf8.RuneCountInString(e.Text))
+	case EventEndOfTransmission:
+		return ""
+	}
+	return e.Text
+}
+
+// Text Events put together as they would be printed on the string channel.
+func Text(events []Event) string {
+	b := strings.Builder{}
+	for _, e := range events {
+		b.WriteString(e.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,142 @@
+package decode
+
+import (
+	"testing"
+	"time"
+)
+
+// runEventDecoder Feed all detections to a new event decoder, stop it and return all the events it sent.
+func runEventDecoder(config DecoderConfig, detections []Detection) []Event {
+	decodeIn := make(chan Detection)
+	decodeOut := make(chan Event)
+	done := make(chan struct{})
+
+	decoder := NewMorseEventDecoder(decodeIn, decodeOut, done, config)
+	decoder.StartDecode()
+
+	output := make(chan []Event)
+	go func() {
+		events := []Event{}
+		for e := range decodeOut {
+			events = append(events, e)
+		}
+		output <- events
+	}()
+
+	for _, detection := range detections {
+		decodeIn <- detection
+	}
+	close(done)
+
+	return <-output
+}
+
+func Test_DecodeEvents(t *testing.T) {
+	ditLength := 48 * time.Millisecond
+
+	config := DecoderConfig{
+		Wpm:      25,
+		Tolerace: 0.4,
+	}
+
+	detections := encode(t, EncoderConfig{Wpm: 25, Table: undecodable}, "C %")
+	detections = append(detections, Detection{State: false, Duration: 2 * time.Second})
+
+	exp := []Event{
+		{Kind: EventChar, Text: "C", Pattern: "-.-.", Start: 0, End: 11 * ditLength, Confidence: 1},
+		{Kind: EventWordBreak, Start: 11 * ditLength, End: 11 * ditLength, Confidence: 1},
+		{Kind: EventUnknown, Text: "|?|", Pattern: "......", Start: 18 * ditLength, End: 29 * ditLength, Confidence: 1},
+		{Kind: EventEndOfTransmission, Start: 32 * ditLength, End: 32 * ditLength, Confidence: 1},
+	}
+
+	events := runEventDecoder(config, detections)
+
+	if len(events) != len(exp) {
+		t.Fatalf("expecting [%d] events, got [%d]: %#v", len(exp), len(events), events)
+	}
+
+	for i, e := range events {
+		if e.Wpm != 25 {
+			t.Errorf("expecting event [%d] at [25] WPM, got [%.2f]", i, e.Wpm)
+		}
+
+		e.Wpm = 0
+		if e != exp[i] {
+			t.Errorf("expecting event [%d] to be [%#v], got [%#v]", i, exp[i], e)
+		}
+	}
+}
+
+func Test_Confidence(t *testing.T) {
+	ditLength := 48 * time.Millisecond
+
+	testCases := []struct {
+		name       string
+		detections []Detection
+		min        float64
+		max        float64
+	}{
+		{
+			name: "exact_timing",
+			detections: []Detection{
+				{State: true, Duration: ditLength},
+				{State: false, Duration: ditLength},
+				{State: true, Duration: 3 * ditLength},
+				{State: false, Duration: 3 * ditLength},
+			},
+			min: 1,
+			max: 1,
+		},
+		{
+			name: "slightly_off_dah",
+			detections: []Detection{
+				{State: true, Duration: ditLength},
+				{State: false, Duration: ditLength},
+				{State: true, Duration: time.Duration(float64(3*ditLength) * 1.1)},
+				{State: false, Duration: 3 * ditLength},
+			},
+			min: 0.7,
+			max: 0.8,
+		},
+		{
+			name: "dah_at_edge_of_tolerance",
+			detections: []Detection{
+				{State: true, Duration: ditLength},
+				{State: false, Duration: ditLength},
+				{State: true, Duration: time.Duration(float64(3*ditLength) * 1.39)},
+				{State: false, Duration: 3 * ditLength},
+			},
+			min: 0,
+			max: 0.05,
+		},
+		{
+			name: "off_gap",
+			detections: []Detection{
+				{State: true, Duration: ditLength},
+				{State: false, Duration: time.Duration(float64(ditLength) * 0.8)},
+				{State: true, Duration: 3 * ditLength},
+				{State: false, Duration: 3 * ditLength},
+			},
+			min: 0.45,
+			max: 0.55,
+		},
+	}
+
+	config := DecoderConfig{
+		Wpm:      25,
+		Tolerace: 0.4,
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			events := runEventDecoder(config, tc.detections)
+			if len(events) == 0 || events[0].Text != "A" {
+				t.Fatalf("expecting [A] to be decoded, got %#v", events)
+			}
+
+			if c := events[0].Confidence; c < tc.min || c > tc.max {
+				t.Errorf("expecting confidence between [%.2f] and [%.2f], got [%.2f]", tc.min, tc.max, c)
+			}
+		})
+	}
+}
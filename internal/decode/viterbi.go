@@ -0,0 +1,309 @@
+package decode
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// Number of marks held before decoding is forced when the sender doesn't pause between words.
+	viterbiWindowSize = 32
+	// Log-likelihood penalty for a sequence that maps to no code at all.
+	viterbiUnknownPenalty = -10.0
+
+	// Floors keeping the likelihoods finite for empty detections and no tolerance: shortest duration (ms) and narrowest
+	// spread.
+	viterbiMinDuration = 0.1
+	viterbiMinSigma    = 0.01
+)
+
+// Kinds of gap in the Viterbi model.
+const (
+	gapElement = iota
+	gapChar
+	gapWord
+)
+
+type viterbiCode struct {
+	pattern string
+	node    *treeNode
+}
+
+// viterbiStep Best way found to decode the marks up to some point, and the character that got us there.
+type viterbiStep struct {
+	score   float64
+	length  int // Marks in the character.
+	code    int // Index in the codes, -1 for an unknown sequence.
+	wordEnd bool
+}
+
+// decodeViterbi Hold the detections in a window until a word gap (or a full window), then find the most likely
+// character sequence for the whole window. Element and gap durations are modeled as log-normal around their ideal
+// length, so no single borderline element throws the character away.
+//
+// Shift prosigns only take effect for the next window.
+func (md *MorseDecoder) decodeViterbi(d Detection) {
+	if d.State {
+		if md.config.Adaptive {
+			md.trackSpeed(d.Duration)
+		}
+
+		if len(md.window) == 0 {
+			md.windowStart = md.clock
+		}
+		md.window = append(md.window, d)
+		return
+	}
+
+	// Nothing being received, a long enough silence ends the transmission.
+	if len(md.window) == 0 {
+		if md.beyondWordLength(d.Duration) {
+			md.endTransmission()
+		}
+		return
+	}
+	md.window = append(md.window, d)
+
+	gaps := md.gapLikelihoods(d.Duration)
+	wordEnd := gaps[gapWord] > gaps[gapChar] && gaps[gapWord] > gaps[gapElement]
+	if wordEnd || len(md.window)/2 >= viterbiWindowSize {
+		md.decodeWindow(!wordEnd)
+	}
+
+	if md.beyondWordLength(d.Duration) {
+		md.endTransmission()
+	}
+}
+
+// flushViterbi Decode everything left in the window, closing the last character if needed.
+func (md *MorseDecoder) flushViterbi() {
+	if len(md.window) == 0 {
+		return
+	}
+
+	if len(md.window)%2 == 1 {
+		charGap := time.Duration(3 * md.spacingLength() * float64(time.Millisecond))
+		md.window = append(md.window, Detection{State: false, Duration: charGap})
+	}
+	md.decodeWindow(false)
+}
+
+// decodeWindow Find the best segmentation of the window in characters and emit them. When keepLast is set, the last
+// character is held back so it can be decoded again along with what comes next.
+func (md *MorseDecoder) decodeWindow(keepLast bool) {
+	codes := md.viterbiCodes()
+	maxLength := 0
+	for _, c := range codes {
+		maxLength = max(maxLength, len(c.pattern))
+	}
+
+	marks := len(md.window) / 2
+	markLL := make([][2]float64, marks)
+	gapLL := make([][3]float64, marks)
+	for i := range marks {
+		markLL[i] = md.markLikelihoods(md.window[2*i].Duration)
+		gapLL[i] = md.gapLikelihoods(md.window[2*i+1].Duration)
+	}
+
+	steps := make([]viterbiStep, marks+1)
+	for i := range steps[1:] {
+		steps[i+1].score = math.Inf(-1)
+	}
+
+	for i := range marks {
+		if math.IsInf(steps[i].score, -1) {
+			continue
+		}
+
+		// Unknown sequences can run longer than any code.
+		for length := 1; i+length <= marks; length++ {
+			end := i + length - 1
+
+			score := steps[i].score
+			for k := i; k < end; k++ {
+				score += gapLL[k][gapElement]
+			}
+
+			wordEnd := gapLL[end][gapWord] > gapLL[end][gapChar]
+			if wordEnd {
+				score += gapLL[end][gapWord]
+			} else {
+				score += gapLL[end][gapChar]
+			}
+
+			consider := func(s float64, code int) {
+				if s > steps[end+1].score {
+					steps[end+1] = viterbiStep{score: s, length: length, code: code, wordEnd: wordEnd}
+				}
+			}
+
+			unknown := score + viterbiUnknownPenalty
+			for k := i; k <= end; k++ {
+				unknown += max(markLL[k][0], markLL[k][1])
+			}
+			consider(unknown, -1)
+
+			if length > maxLength {
+				continue
+			}
+			for c, code := range codes {
+				if len(code.pattern) == length {
+					consider(score+patternLikelihood(markLL[i:end+1], code.pattern), c)
+				}
+			}
+		}
+	}
+
+	// Walk back from the end to get the characters in order. Should no way have been found to some point, the marks up
+	// to it are taken as an unknown sequence rather than walking back forever.
+	path := []viterbiStep{}
+	for i := marks; i > 0; i -= steps[i].length {
+		if steps[i].length == 0 {
+			path = append(path, viterbiStep{length: i, code: -1})
+			break
+		}
+		path = append(path, steps[i])
+	}
+	slices.Reverse(path)
+
+	if keepLast && len(path) > 1 {
+		path = path[:len(path)-1]
+	}
+
+	md.commitWindow(path, codes, markLL)
+}
+
+// commitWindow Emit the characters found in the window and drop their detections from it.
+func (md *MorseDecoder) commitWindow(path []viterbiStep, codes []viterbiCode, markLL [][2]float64) {
+	starts := make([]time.Duration, len(md.window)+1)
+	starts[0] = md.windowStart
+	for i, d := range md.window {
+		starts[i+1] = starts[i] + d.Duration
+	}
+
+	mark := 0
+	for _, step := range path {
+		md.charStart = starts[2*mark]
+		md.charEnd = starts[2*(mark+step.length)-1]
+
+		if step.code < 0 {
+			pattern := strings.Builder{}
+			for _, ll := range markLL[mark : mark+step.length] {
+				if ll[0] >= ll[1] {
+					pattern.WriteByte('.')
+				} else {
+					pattern.WriteByte('-')
+				}
+			}
+			md.pattern = pattern.String()
+			md.confidence = 0
+			md.unknown()
+			if step.wordEnd {
+				md.queue(EventWordBreak, "")
+			}
+
+		} else {
+			md.pattern = codes[step.code].pattern
+			md.confidence = codeConfidence(markLL[mark:mark+step.length], codes, step.code)
+			md.currentNode = codes[step.code].node
+			md.output(step.wordEnd)
+			md.currentNode = md.root
+		}
+
+		mark += step.length
+	}
+
+	md.windowStart = starts[2*mark]
+	md.window = append(md.window[:0], md.window[2*mark:]...)
+}
+
+// codeConfidence Probability of the chosen code against all other codes of the same length, given the marks.
+func codeConfidence(markLL [][2]float64, codes []viterbiCode, chosen int) float64 {
+	best := patternLikelihood(markLL, codes[chosen].pattern)
+
+	total := 0.0
+	for _, code := range codes {
+		if len(code.pattern) == len(markLL) {
+			total += math.Exp(patternLikelihood(markLL, code.pattern) - best)
+		}
+	}
+
+	return 1 / total
+}
+
+func patternLikelihood(markLL [][2]float64, pattern string) float64 {
+	score := 0.0
+	for i := range len(pattern) {
+		if pattern[i] == '.' {
+			score += markLL[i][0]
+		} else {
+			score += markLL[i][1]
+		}
+	}
+	return score
+}
+
+// markLikelihoods Log-likelihoods of the mark being a dit or a dah.
+func (md *MorseDecoder) markLikelihoods(d time.Duration) [2]float64 {
+	ditLength := md.currentDitLength()
+	return [2]float64{
+		md.logLikelihood(d, ditLength, false),
+		md.logLikelihood(d, 3*ditLength, false),
+	}
+}
+
+// gapLikelihoods Log-likelihoods of the gap being between elements, characters or words. Gaps longer than a word gap
+// are word gaps.
+func (md *MorseDecoder) gapLikelihoods(d time.Duration) [3]float64 {
+	ditLength := md.currentDitLength()
+	spacingLength := md.spacingLength()
+	return [3]float64{
+		md.logLikelihood(d, ditLength, false),
+		md.logLikelihood(d, 3*spacingLength, false),
+		md.logLikelihood(d, 7*spacingLength, true),
+	}
+}
+
+// logLikelihood Log-normal likelihood (up to a constant) of the duration given the expected length (ms). The spread
+// comes from the configured tolerance. When open ended, anything longer than expected is as likely as the expected
+// length.
+func (md *MorseDecoder) logLikelihood(d time.Duration, expected float64, openEnded bool) float64 {
+	sigma := max(math.Log(1+md.config.Tolerace), viterbiMinSigma)
+	x := math.Log(max(float64(d)/float64(time.Millisecond), viterbiMinDuration) / expected)
+	if openEnded && x > 0 {
+		return 0
+	}
+
+	return -x * x / (2 * sigma * sigma)
+}
+
+// viterbiCodes Codes of the active table with the tree node each one ends on, built the first time a table is used.
+func (md *MorseDecoder) viterbiCodes() []viterbiCode {
+	if codes, ok := md.codes[md.table]; ok {
+		return codes
+	}
+
+	patterns := []string{}
+	for _, code := range md.table.Chars {
+		patterns = append(patterns, code)
+	}
+	for prosign, code := range md.table.Prosigns {
+		if _, shift := md.table.Shifts[prosign]; md.config.Prosigns || shift {
+			patterns = append(patterns, code)
+		}
+	}
+
+	// Prosigns can share codes with characters, the node already picks the right one.
+	slices.Sort(patterns)
+	patterns = slices.Compact(patterns)
+
+	codes := make([]viterbiCode, 0, len(patterns))
+	for _, pattern := range patterns {
+		codes = append(codes, viterbiCode{pattern: pattern, node: lookupCode(md.root, pattern)})
+	}
+	md.codes[md.table] = codes
+
+	return codes
+}
@@ -0,0 +1,116 @@
+package decode
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// jitter Stretch and shrink the detections as a sloppy hand-sent signal would.
+func jitter(detections []Detection) []Detection {
+	factors := []float64{1.25, 0.8, 1.15, 0.85, 1.3, 0.75, 1.1}
+
+	jittered := make([]Detection, len(detections))
+	for i, d := range detections {
+		jittered[i] = Detection{State: d.State, Duration: time.Duration(float64(d.Duration) * factors[i%len(factors)])}
+	}
+	return jittered
+}
+
+func Test_DecodeViterbi(t *testing.T) {
+	encoding := EncoderConfig{Wpm: 25}
+
+	testCases := []struct {
+		name       string
+		detections []Detection
+		exp        string
+	}{
+		{
+			name:       "clean_timing",
+			detections: encode(t, encoding, "CQ DE W1AW"),
+			exp:        "CQ DE W1AW",
+		},
+		{
+			name:       "sloppy_timing",
+			detections: jitter(encode(t, encoding, "CQ DE W1AW")),
+			exp:        "CQ DE W1AW",
+		},
+		{
+			name:       "long_run_without_word_gaps",
+			detections: encode(t, encoding, strings.Repeat("PARIS", 10)),
+			exp:        strings.Repeat("PARIS", 10),
+		},
+		{
+			name:       "unknown_sequence",
+			detections: encode(t, encoding, "<HH> E"),
+			exp:        "|?| E",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := DecoderConfig{
+				Wpm:      25,
+				Tolerace: 0.2,
+				Mode:     ModeViterbi,
+			}
+
+			if _, text := runDecoder(config, tc.detections); text != tc.exp {
+				t.Errorf("expecting [%s], got [%s]", tc.exp, text)
+			}
+		})
+	}
+}
+
+func Test_DecodeViterbiOutperformsTree(t *testing.T) {
+	detections := jitter(encode(t, EncoderConfig{Wpm: 25}, "CQ DE W1AW"))
+
+	config := DecoderConfig{
+		Wpm:      25,
+		Tolerace: 0.2,
+	}
+
+	if _, text := runDecoder(config, detections); text == "CQ DE W1AW" {
+		t.Errorf("expecting the tree decoder to fail on sloppy timing, got [%s]", text)
+	}
+}
+
+func Test_DecodeViterbiDegenerate(t *testing.T) {
+	sos := encode(t, EncoderConfig{Wpm: 25}, "SOS")
+
+	testCases := []struct {
+		name       string
+		tolerance  float64
+		detections []Detection
+	}{
+		{
+			name:       "empty_mark",
+			tolerance:  0.2,
+			detections: append([]Detection{{State: true}, {State: false, Duration: 144 * time.Millisecond}}, sos...),
+		},
+		{name: "no_tolerance", detections: sos},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decoder := NewDecoder(DecoderConfig{Wpm: 25, Tolerace: 0.2, Mode: ModeViterbi})
+			// Not allowed through the configuration, but the likelihoods have to hold anyway.
+			decoder.config.Tolerace = tc.tolerance
+
+			output := make(chan string)
+			go func() {
+				events := decoder.Decode(tc.detections)
+				output <- Text(append(events, decoder.Flush()...))
+			}()
+
+			select {
+			case text := <-output:
+				if !strings.HasSuffix(text, "SOS") {
+					t.Errorf("expecting [SOS] at the end, got [%s]", text)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("decoding didn't return")
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package detect
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/rebay1982/gdsp/fft"
+	"github.com/rebay1982/gdsp/filters"
+	"github.com/rebay1982/gdsp/windowing"
+	"github.com/rebay1982/gmorse/internal/audio"
+	"github.com/rebay1982/gmorse/internal/decode"
+)
+
+const (
+	defaultResolution     = 16 * time.Millisecond
+	defaultThreshold      = 1.0
+	defaultSilenceTimeout = 2 * time.Second
+
+	defaultAttack     = 20 * time.Millisecond
+	defaultDecay      = 2 * time.Second
+	defaultNoiseTime  = 200 * time.Millisecond
+	defaultHysteresis = 3.0
+	defaultMinSNR     = 8.0
+)
+
+var defaultFrequencies = []float64{500, 550, 600, 650, 700, 750, 800, 850, 900, 950}
+
+// Listening on the bin of the carrier and those either side of it, the strongest of a few frequencies is measured the
+// same way as over the default ones. The noise floor holds when the carrier is found, and varies less than on a single
+// frequency. Offsets are in Goertzel bins, closer frequencies would fall in the same bins.
+var carrierBins = []float64{-1, 0, 1}
+
+// Config Where to look for a tone and how strong it has to be.
+type Config struct {
+	SampleRate int
+	// Channel Channel listened to in blocks of multichannel audio, from 0 (the first, by default).
+	Channel int
+
+	// Frequencies Tone frequencies to listen on, defaults to 500Hz to 950Hz in 50Hz steps.
+	Frequencies []float64
+	// Threshold Magnitude above which a tone is detected on any of the frequencies, defaults to 1.0.
+	Threshold float64
+	// Resolution Audio the Goertzel filters run over at once, defaults to 16ms (128 samples at 8kHz). Shorter times tell
+	// the edges of the tone more precisely, longer ones tell closer frequencies apart.
+	Resolution time.Duration
+	// BlockSize Samples the Goertzel filters run over, defaults to Resolution at the sample rate. Shorter blocks are
+	// zero padded.
+	BlockSize int
+
+	// SilenceTimeout Silence after which the gap is reported without waiting for the next mark, so the decoder can end
+	// the word. Defaults to 2s.
+	SilenceTimeout time.Duration
+
+	// Interpolate Place the start and end of the tone within the block, where the magnitude crosses the threshold, rather
+	// than on block boundaries.
+	Interpolate bool
+
+	// Adaptive Follow the noise floor and the level of the tone, and detect the tone half way between them (in dB)
+	// instead of above Threshold. Works whatever the input gain and band noise.
+	Adaptive bool
+	// Attack Time constant for the tone level to follow a stronger tone, defaults to 20ms so that it is there by the end
+	// of the first mark.
+	Attack time.Duration
+	// Decay Time constant for the tone level to follow a weaker tone, and the noise floor to rise during a mark. Defaults
+	// to 2s, long enough to hold the levels through the gaps and marks.
+	Decay time.Duration
+	// NoiseTime Time constant the noise floor is averaged over between marks, defaults to 200ms.
+	NoiseTime time.Duration
+	// Hysteresis dB between the thresholds the tone starts and stops at, so that it doesn't chatter around the middle.
+	// Defaults to 3dB.
+	Hysteresis float64
+	// MinSNR dB the tone has to stand above the noise floor to be detected at all, defaults to 8dB. Below that, it's
+	// noise.
+	MinSNR float64
+
+	// Track Look for the carrier and, once found, listen on it rather than on all of Frequencies, following it as it
+	// drifts. Frequencies are listened on until then.
+	Track bool
+	// Tracker How the carrier is looked for and followed with Track, at the sample rate of the detector.
+	Tracker TrackerConfig
+}
+
+// Detector Finds a tone in blocks of audio and times the marks and gaps, for the decoder to turn into text. Timing
+// comes from counting samples, so it doesn't matter when or how fast the blocks come in. A detector holds its own
+// state, one is needed per signal.
+type Detector struct {
+	config Config
+
+	// Avoid recreating these for every block.
+	pcm     []float64
+	samples []float64
+	mags    []float64
+
+	// Frequencies listened on, those of the configuration or the carrier once found.
+	frequencies []float64
+	tracker     *Tracker
+	carrier     []float64
+
+	// Samples received so far.
+	position int
+
+	started bool
+	state   bool
+	// Sample position (fractional when interpolating) the current mark or gap started at, and whether the gap up to it
+	// was returned on time out.
+	since    float64
+	timedOut bool
+
+	// Strongest magnitude and size of the previous block, and the magnitude of the tone when fully on, to interpolate
+	// the edges.
+	lastMag    float64
+	lastLength int
+	level      float64
+	markLevel  float64
+
+	// Adaptive threshold: tone level and noise floor in dB, and whether the tone was on for the last block.
+	tracked time.Duration
+	signal  float64
+	floor   float64
+	on      bool
+}
+
+// NewDetector Create a detector.
+func NewDetector(cfg Config) *Detector {
+	if len(cfg.Frequencies) == 0 {
+		cfg.Frequencies = defaultFrequencies
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultThreshold
+	}
+	if cfg.Resolution <= 0 {
+		cfg.Resolution = defaultResolution
+	}
+	if cfg.BlockSize <= 0 {
+		cfg.BlockSize = max(int(math.Round(cfg.Resolution.Seconds()*float64(cfg.SampleRate))), 1)
+	}
+	if cfg.SilenceTimeout <= 0 {
+		cfg.SilenceTimeout = defaultSilenceTimeout
+	}
+	if cfg.Attack <= 0 {
+		cfg.Attack = defaultAttack
+	}
+	if cfg.Decay <= 0 {
+		cfg.Decay = defaultDecay
+	}
+	if cfg.NoiseTime <= 0 {
+		cfg.NoiseTime = defaultNoiseTime
+	}
+	if cfg.Hysteresis <= 0 {
+		cfg.Hysteresis = defaultHysteresis
+	}
+	if cfg.MinSNR <= 0 {
+		cfg.MinSNR = defaultMinSNR
+	}
+
+	d := &Detector{
+		config:      cfg,
+		samples:     make([]float64, cfg.BlockSize),
+		mags:        make([]float64, len(cfg.Frequencies)),
+		frequencies: cfg.Frequencies,
+	}
+	if cfg.Track {
+		cfg.Tracker.SampleRate = cfg.SampleRate
+		d.tracker = NewTracker(cfg.Tracker)
+		d.carrier = make([]float64, len(carrierBins))
+	}
+	return d
+}
+
+// ProcessBlock Same as Process, for a block handed over by an audio source. Only Channel is listened to.
+func (d *Detector) ProcessBlock(block audio.Block) []decode.Detection {
+	d.pcm = block.Samples(d.config.Channel, d.pcm[:0])
+	return d.Process(d.pcm)
+}
+
+// Run Detect the tone in the audio of the source, sending the detections on out, until the audio runs out or the
+// context is done. What is being received is flushed at the end, and out is closed on return.
+func (d *Detector) Run(ctx context.Context, source audio.Source, out chan<- decode.Detection) error {
+	defer close(out)
+
+	err := source.Run(ctx, func(block audio.Block) {
+		for _, detection := range d.ProcessBlock(block) {
+			select {
+			case out <- detection:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	if detection, ok := d.Flush(); ok {
+		select {
+		case out <- detection:
+		case <-ctx.Done():
+		}
+	}
+	return err
+}
+
+// Process Look for the tone in the next samples (normalized to [-1, 1]), BlockSize at a time, however many come in at
+// once. Every time the tone starts or stops, the detection that just ended is returned. After a long silence, the gap
+// so far is returned and timing starts over.
+func (d *Detector) Process(samples []float64) []decode.Detection {
+	var detections []decode.Detection
+	for block := range slices.Chunk(samples, d.config.BlockSize) {
+		if detection, ok := d.process(block); ok {
+			detections = append(detections, detection)
+		}
+	}
+	return detections
+}
+
+// process Look for the tone in a block of up to BlockSize samples, returning the detection it ends, if any.
+func (d *Detector) process(samples []float64) (decode.Detection, bool) {
+	detected := d.Detect(samples)
+	mag := slices.Max(d.mags)
+
+	start := d.position
+	d.position += len(samples)
+	defer func() {
+		d.lastMag = mag
+		d.lastLength = len(samples)
+	}()
+
+	if !d.started {
+		d.started = true
+		d.state = detected
+		d.since = float64(start)
+		return decode.Detection{}, false
+	}
+
+	if detected {
+		d.markLevel = max(d.markLevel, mag)
+	}
+
+	// Edge detection.
+	if detected != d.state {
+		edge := float64(start)
+		if d.config.Interpolate {
+			edge = d.interpolate(detected, start, mag, len(samples))
+		}
+		// Toggling back at or before the start of the current mark or gap is a glitch, which the mark or gap carries
+		// on over. Right after a time out though, the gap so far is already returned and the tone starts there.
+		if edge <= d.since && !d.timedOut {
+			return decode.Detection{}, false
+		}
+		if !detected {
+			d.level = d.markLevel
+			d.markLevel = 0
+		}
+
+		detection := decode.Detection{State: d.state, Duration: d.duration(edge - d.since)}
+		d.state = detected
+		d.timedOut = false
+		if edge <= d.since {
+			return decode.Detection{}, false
+		}
+		d.since = edge
+		return detection, true
+	}
+
+	// Time out after a while of silence.
+	if !detected && d.duration(float64(d.position)-d.since) > d.config.SilenceTimeout {
+		detection := decode.Detection{State: false, Duration: d.duration(float64(d.position) - d.since)}
+		d.since = float64(d.position)
+		d.timedOut = true
+		return detection, true
+	}
+
+	return decode.Detection{}, false
+}
+
+// interpolate Sample position of the edge found at the block starting at start, from how much of this block and the
+// previous one the tone covers. The magnitude of a block grows with the part covered by the tone, as weighted by the
+// window, up to the level of a full block.
+func (d *Detector) interpolate(rising bool, start int, mag float64, length int) float64 {
+	level := d.level
+	if !rising {
+		level = d.markLevel
+	}
+	if level <= 0 {
+		return float64(start)
+	}
+
+	covered := hannCoverage(d.lastMag/level)*float64(d.lastLength) + hannCoverage(mag/level)*float64(length)
+
+	// Starting towards the end of the previous block, or ending towards the start of this one.
+	edge := float64(start+length) - covered
+	if !rising {
+		edge = float64(start-d.lastLength) + covered
+	}
+	return edge
+}
+
+// hannCoverage Fraction of a Hann windowed block a tone has to cover, from one end, for the block to get the given
+// fraction of the full magnitude.
+func hannCoverage(fraction float64) float64 {
+	if fraction >= 1 {
+		return 1
+	}
+
+	// The covered part of the window grows as f - sin(2πf)/2π, find f by bisection.
+	low, high := 0.0, 1.0
+	for range 20 {
+		f := (low + high) / 2
+		if f-math.Sin(2*math.Pi*f)/(2*math.Pi) < fraction {
+			low = f
+		} else {
+			high = f
+		}
+	}
+	return (low + high) / 2
+}
+
+// Flush Return what is being received, e.g. at the end of a recording.
+func (d *Detector) Flush() (decode.Detection, bool) {
+	if !d.started || float64(d.position) <= d.since {
+		return decode.Detection{}, false
+	}
+
+	detection := decode.Detection{State: d.state, Duration: d.duration(float64(d.position) - d.since)}
+	d.since = float64(d.position)
+	return detection, true
+}
+
+// duration Time taken by a number of samples.
+func (d *Detector) duration(samples float64) time.Duration {
+	return time.Duration(samples / float64(d.config.SampleRate) * float64(time.Second))
+}
+
+// Detect Whether the tone is in the block, on any of the frequencies. With Adaptive or Track, this also updates the
+// noise floor and tone level or the carrier, so blocks have to come in order.
+func (d *Detector) Detect(samples []float64) bool {
+	if d.tracker != nil {
+		d.tracker.Process(samples)
+		if frequency, ok := d.tracker.Frequency(); ok {
+			width := float64(d.config.SampleRate) / float64(d.config.BlockSize)
+			for i, bin := range carrierBins {
+				d.carrier[i] = frequency + bin*width
+			}
+			d.SetFrequencies(d.carrier)
+		}
+	}
+
+	n := copy(d.samples, samples)
+	clear(d.samples[n:])
+
+	// Window (reduces spectral leakage)
+	// Only apply it to the samples, not the padding.
+	windowing.Hann(d.samples[:n])
+
+	for i, f := range d.frequencies {
+		goertzel := filters.Goertzel(float64(d.config.SampleRate), f, d.samples)
+		d.mags[i] = fft.ComputeMagnitude(goertzel) * 2 // Compensate for the Hanning window
+	}
+
+	if d.config.Adaptive {
+		return d.track(slices.Max(d.mags), len(samples))
+	}
+	return slices.Max(d.mags) > d.config.Threshold
+}
+
+// track Update the tone level and noise floor with the magnitude of a block of the given length, and tell whether the
+// tone is on. The tone level rises quickly and falls slowly so that it holds through the gaps. The noise floor is the
+// average between marks, only creeping up during them so that it can't get stuck under a sudden rise of the noise.
+func (d *Detector) track(mag float64, length int) bool {
+	level := 20 * math.Log10(max(mag, 1e-9))
+	if d.tracked == 0 {
+		d.signal = level
+		d.floor = level
+	}
+	d.tracked += d.duration(float64(length))
+
+	attack := d.smoothing(d.config.Attack, length)
+	decay := d.smoothing(d.config.Decay, length)
+	if level > d.signal {
+		d.signal += attack * (level - d.signal)
+	} else {
+		d.signal += decay * (level - d.signal)
+	}
+	if !d.on {
+		// Plain average to begin with, rather than going by the first block.
+		weight := max(d.smoothing(d.config.NoiseTime, length), d.duration(float64(length)).Seconds()/d.tracked.Seconds())
+		d.floor += weight * (level - d.floor)
+	} else if level > d.floor {
+		d.floor += decay * (level - d.floor)
+	}
+
+	if d.SNR() < d.config.MinSNR {
+		d.on = false
+		return false
+	}
+
+	threshold := (d.signal + d.floor) / 2
+	if d.on {
+		d.on = level > threshold-d.config.Hysteresis/2
+	} else {
+		d.on = level > max(threshold+d.config.Hysteresis/2, d.floor+d.config.MinSNR)
+	}
+	return d.on
+}
+
+// smoothing Weight of a block of the given length for a time constant.
+func (d *Detector) smoothing(constant time.Duration, length int) float64 {
+	return 1 - math.Exp(-d.duration(float64(length)).Seconds()/constant.Seconds())
+}
+
+// SNR dB between the tone level and the noise floor, as tracked with Adaptive.
+func (d *Detector) SNR() float64 {
+	return d.signal - d.floor
+}
+
+// Magnitudes Magnitude on each frequency for the last block.
+func (d *Detector) Magnitudes() []float64 {
+	return d.mags
+}
+
+// Frequencies Frequencies listened on, around the carrier once found with Track.
+func (d *Detector) Frequencies() []float64 {
+	return d.frequencies
+}
+
+// SetFrequencies Listen on other frequencies from the next block on, e.g. to follow a carrier found elsewhere. Timing
+// and levels carry on. Without any frequency, the detector keeps listening where it was.
+func (d *Detector) SetFrequencies(frequencies []float64) {
+	if len(frequencies) == 0 {
+		return
+	}
+
+	d.frequencies = frequencies
+	d.mags = slices.Grow(d.mags[:0], len(frequencies))[:len(frequencies)]
+}
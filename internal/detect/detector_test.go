@@ -0,0 +1,408 @@
+package detect
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/audio"
+	"github.com/rebay1982/gmorse/internal/decode"
+	"github.com/rebay1982/gmorse/internal/synth"
+)
+
+// run Feed the samples to the detector in 10ms blocks, as a sound card would.
+func run(detector *Detector, samples []int16, sampleRate int) []decode.Detection {
+	frames := sampleRate / 100
+
+	detections := []decode.Detection{}
+	for i := 0; i+frames <= len(samples); i += frames {
+		block := make([]float64, frames)
+		for j := range block {
+			block[j] = float64(samples[i+j]) / math.MaxInt16
+		}
+
+		detections = append(detections, detector.Process(block)...)
+	}
+	return detections
+}
+
+func Test_Detect(t *testing.T) {
+	testCases := []struct {
+		name      string
+		frequency float64
+		amplitude float64
+		exp       bool
+	}{
+		{name: "tone_in_band", frequency: 700, amplitude: 0.5, exp: true},
+		{name: "weak_tone", frequency: 700, amplitude: 0.001, exp: false},
+		{name: "tone_out_of_band", frequency: 2000, amplitude: 0.5, exp: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			block := make([]float64, 80)
+			for i := range block {
+				block[i] = tc.amplitude * math.Sin(2*math.Pi*tc.frequency*float64(i)/8000)
+			}
+
+			if detected := NewDetector(Config{SampleRate: 8000}).Detect(block); detected != tc.exp {
+				t.Errorf("expecting detection to be %v, got %v", tc.exp, detected)
+			}
+		})
+	}
+}
+
+func Test_Process(t *testing.T) {
+	synthesizer := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 700})
+	samples := synthesizer.Silence(100 * time.Millisecond)
+	samples = append(samples, synthesizer.Render([]decode.Detection{
+		{State: true, Duration: 60 * time.Millisecond},
+		{State: false, Duration: 60 * time.Millisecond},
+		{State: true, Duration: 180 * time.Millisecond},
+		{State: false, Duration: 500 * time.Millisecond},
+	})...)
+
+	detections := run(NewDetector(Config{SampleRate: 8000, SilenceTimeout: 300 * time.Millisecond}), samples, 8000)
+
+	// The leading silence, the marks and the gap between them, then the long gap reported on time out.
+	exp := []decode.Detection{
+		{State: false, Duration: 100 * time.Millisecond},
+		{State: true, Duration: 60 * time.Millisecond},
+		{State: false, Duration: 60 * time.Millisecond},
+		{State: true, Duration: 180 * time.Millisecond},
+		{State: false, Duration: 300 * time.Millisecond},
+	}
+	if len(detections) != len(exp) {
+		t.Fatalf("expecting %v, got %v", exp, detections)
+	}
+	for i, d := range detections {
+		if d.State != exp[i].State || (d.Duration-exp[i].Duration).Abs() > 20*time.Millisecond {
+			t.Errorf("expecting %v at %d, got %v", exp[i], i, d)
+		}
+	}
+}
+
+func Test_DetectorsIndependent(t *testing.T) {
+	synthesizer := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 600})
+	samples := append(synthesizer.Silence(50*time.Millisecond), synthesizer.Render([]decode.Detection{
+		{State: true, Duration: 100 * time.Millisecond},
+		{State: false, Duration: 100 * time.Millisecond},
+	})...)
+
+	// Listening on different frequencies, only one of them hears the tone.
+	hearing := NewDetector(Config{SampleRate: 8000, Frequencies: []float64{600}})
+	deaf := NewDetector(Config{SampleRate: 8000, Frequencies: []float64{1500}})
+
+	if detections := run(hearing, samples, 8000); len(detections) != 2 {
+		t.Errorf("expecting the silence and the mark, got %v", detections)
+	}
+	if detections := run(deaf, samples, 8000); len(detections) != 0 {
+		t.Errorf("expecting nothing, got %v", detections)
+	}
+}
+
+func Test_ProcessInterpolated(t *testing.T) {
+	// Marks and gaps falling anywhere within the 10ms blocks.
+	keying := []decode.Detection{
+		{State: false, Duration: 103 * time.Millisecond},
+		{State: true, Duration: 57 * time.Millisecond},
+		{State: false, Duration: 61 * time.Millisecond},
+		{State: true, Duration: 174 * time.Millisecond},
+		{State: false, Duration: 66 * time.Millisecond},
+		{State: true, Duration: 52 * time.Millisecond},
+		{State: false, Duration: 200 * time.Millisecond},
+	}
+	// Hard keyed, ramps would make the marks sound shorter than keyed.
+	samples := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 700, RiseTime: time.Microsecond}).
+		Render(keying)
+
+	// Error on each mark and gap once the level of the tone is known, from the end of the first mark.
+	maxError := func(detections []decode.Detection) time.Duration {
+		worst := time.Duration(0)
+		for i, d := range detections[2:] {
+			worst = max(worst, (d.Duration - keying[i+2].Duration).Abs())
+		}
+		return worst
+	}
+
+	blocks := run(NewDetector(Config{SampleRate: 8000}), samples, 8000)
+	interpolated := run(NewDetector(Config{SampleRate: 8000, Interpolate: true}), samples, 8000)
+	if len(blocks) != 6 || len(interpolated) != 6 {
+		t.Fatalf("expecting 6 detections, got %v and %v", blocks, interpolated)
+	}
+
+	if worst := maxError(interpolated); worst > 3*time.Millisecond {
+		t.Errorf("expecting interpolated timing within 3ms, got %v off", worst)
+	}
+	if maxError(interpolated) >= maxError(blocks) {
+		t.Errorf("expecting interpolation to do better than %v, got %v", maxError(blocks), maxError(interpolated))
+	}
+}
+
+func Test_ProcessIgnoresBlockTiming(t *testing.T) {
+	samples := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 700}).Render([]decode.Detection{
+		{State: false, Duration: 100 * time.Millisecond},
+		{State: true, Duration: 60 * time.Millisecond},
+		{State: false, Duration: 100 * time.Millisecond},
+	})
+
+	// Only the samples count, the same audio gives the same detections whenever it is handed over.
+	first := run(NewDetector(Config{SampleRate: 8000}), samples, 8000)
+	second := run(NewDetector(Config{SampleRate: 8000}), samples, 8000)
+
+	if !slices.Equal(first, second) {
+		t.Errorf("expecting the same detections, got %v and %v", first, second)
+	}
+	if len(first) != 2 || first[1].Duration != 60*time.Millisecond {
+		t.Errorf("expecting a 60ms mark, got %v", first)
+	}
+}
+
+func Test_ProcessLongBlocks(t *testing.T) {
+	keying := []decode.Detection{
+		{State: false, Duration: 100 * time.Millisecond},
+		{State: true, Duration: 60 * time.Millisecond},
+		{State: false, Duration: 60 * time.Millisecond},
+		{State: true, Duration: 180 * time.Millisecond},
+		{State: false, Duration: 100 * time.Millisecond},
+	}
+	samples := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 700}).Render(keying)
+
+	// The whole recording at once is looked at in full, as if it came in 10ms blocks.
+	block := make([]float64, len(samples))
+	for i, sample := range samples {
+		block[i] = float64(sample) / math.MaxInt16
+	}
+	whole := NewDetector(Config{SampleRate: 8000, BlockSize: 80}).Process(block)
+	blocks := run(NewDetector(Config{SampleRate: 8000, BlockSize: 80}), samples, 8000)
+
+	if len(whole) != 4 || !slices.Equal(whole, blocks) {
+		t.Errorf("expecting %v, got %v", blocks, whole)
+	}
+}
+
+func Test_ProcessAdaptive(t *testing.T) {
+	keying := []decode.Detection{{State: false, Duration: 500 * time.Millisecond}}
+	for range 10 {
+		keying = append(keying,
+			decode.Detection{State: true, Duration: 60 * time.Millisecond},
+			decode.Detection{State: false, Duration: 60 * time.Millisecond},
+			decode.Detection{State: true, Duration: 180 * time.Millisecond},
+			decode.Detection{State: false, Duration: 180 * time.Millisecond},
+		)
+	}
+
+	// marks Marks detected and how many were close to being keyed as long, past the first one which sets the level.
+	marks := func(detections []decode.Detection) (int, int) {
+		count, matching := 0, 0
+		for i, d := range detections {
+			if d.State {
+				count++
+				if i == 1 || (d.Duration-60*time.Millisecond).Abs() < 20*time.Millisecond ||
+					(d.Duration-180*time.Millisecond).Abs() < 20*time.Millisecond {
+					matching++
+				}
+			}
+		}
+		return count, matching
+	}
+
+	testCases := []struct {
+		name      string
+		amplitude float64
+	}{
+		{name: "loud", amplitude: 0.5},
+		{name: "quiet", amplitude: 0.002},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			samples := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 700, Amplitude: tc.amplitude}).
+				Render(keying)
+			samples = synth.NewChannel(synth.ChannelConfig{SampleRate: 8000, SNR: 6, Noise: true, Seed: 1}).Apply(samples)
+
+			detector := NewDetector(Config{SampleRate: 8000, Adaptive: true})
+			if count, matching := marks(run(detector, samples, 8000)); count != 20 || matching != 20 {
+				t.Errorf("expecting the 20 marks, got %d with %d of the right length", count, matching)
+			}
+			if snr := detector.SNR(); snr < 10 {
+				t.Errorf("expecting the tone above the noise, got an SNR of %.1fdB", snr)
+			}
+
+			// The fixed threshold misses the quiet tone and chatters on the noise with the loud one.
+			if count, _ := marks(run(NewDetector(Config{SampleRate: 8000}), samples, 8000)); count == 20 {
+				t.Errorf("expecting the fixed threshold to get it wrong, got the 20 marks")
+			}
+		})
+	}
+}
+
+func Test_ProcessAdaptiveNoise(t *testing.T) {
+	// The noise is set against a tone at the end, only listen up to it.
+	samples := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 700}).Render([]decode.Detection{
+		{State: false, Duration: 5 * time.Second},
+		{State: true, Duration: 60 * time.Millisecond},
+	})
+	samples = synth.NewChannel(synth.ChannelConfig{SampleRate: 8000, SNR: 0, Noise: true, Seed: 1}).Apply(samples)
+
+	// Noise alone never stands out of the noise floor.
+	detector := NewDetector(Config{SampleRate: 8000, Adaptive: true})
+	for _, d := range run(detector, samples[:5*8000], 8000) {
+		if d.State {
+			t.Errorf("expecting no marks, got %v", d)
+		}
+	}
+	if snr := detector.SNR(); snr >= 10 {
+		t.Errorf("expecting an SNR below 10dB, got %.1fdB", snr)
+	}
+}
+
+func Test_ProcessNoisyDurations(t *testing.T) {
+	keying := []decode.Detection{{State: false, Duration: 500 * time.Millisecond}}
+	for range 10 {
+		keying = append(keying,
+			decode.Detection{State: true, Duration: 60 * time.Millisecond},
+			decode.Detection{State: false, Duration: 60 * time.Millisecond},
+			decode.Detection{State: true, Duration: 180 * time.Millisecond},
+			decode.Detection{State: false, Duration: 500 * time.Millisecond},
+		)
+	}
+	samples := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 700}).Render(keying)
+
+	testCases := []struct {
+		name   string
+		config Config
+	}{
+		{name: "blocks", config: Config{SampleRate: 8000, Adaptive: true, SilenceTimeout: 100 * time.Millisecond}},
+		{name: "interpolated", config: Config{SampleRate: 8000, Adaptive: true, SilenceTimeout: 100 * time.Millisecond,
+			Interpolate: true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Glitches on the noise toggle the tone right back, they never make for empty marks or gaps.
+			for seed := range uint64(10) {
+				noisy := synth.NewChannel(synth.ChannelConfig{SampleRate: 8000, SNR: 0, Noise: true, Seed: seed}).
+					Apply(samples)
+				detector := NewDetector(tc.config)
+				detections := run(detector, noisy, 8000)
+				if d, ok := detector.Flush(); ok {
+					detections = append(detections, d)
+				}
+
+				for i, d := range detections {
+					if d.Duration <= 0 {
+						t.Fatalf("expecting every detection to last, got %v at %d with seed %d", d, i, seed)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_ProcessTracked(t *testing.T) {
+	// Off the frequencies listened on by default, with another signal keyed close by.
+	samples := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 623}).Render(keyed(6 * time.Second))
+	interference := []decode.Detection{}
+	for range 30 {
+		interference = append(interference,
+			decode.Detection{State: true, Duration: 100 * time.Millisecond},
+			decode.Detection{State: false, Duration: 100 * time.Millisecond},
+		)
+	}
+	samples = synth.NewChannel(synth.ChannelConfig{
+		SampleRate:  8000,
+		SNR:         20,
+		Noise:       true,
+		Interferers: []synth.Interferer{{Frequency: 950, Level: 0.5, Keying: interference}},
+		Seed:        1,
+	}).Apply(samples)
+
+	// Marks keyed after the carrier was found.
+	wrong := func(detections []decode.Detection) int {
+		count, elapsed := 0, time.Duration(0)
+		for _, d := range detections {
+			elapsed += d.Duration
+			if d.State && elapsed > 2*time.Second && (d.Duration-60*time.Millisecond).Abs() > 20*time.Millisecond &&
+				(d.Duration-180*time.Millisecond).Abs() > 20*time.Millisecond {
+				count++
+			}
+		}
+		return count
+	}
+
+	tracked := NewDetector(Config{SampleRate: 8000, Adaptive: true, Track: true})
+	if count := wrong(run(tracked, samples, 8000)); count != 0 {
+		t.Errorf("expecting the marks of the carrier alone, got %d others", count)
+	}
+	if frequencies := tracked.Frequencies(); len(frequencies) != 3 || math.Abs(frequencies[1]-623) > 3 {
+		t.Errorf("expecting to listen around 623Hz, got %v", frequencies)
+	}
+	// A bin (62.5Hz for 128 samples at 8kHz) either side, each its own.
+	if frequencies := tracked.Frequencies(); math.Abs(frequencies[1]-frequencies[0]-62.5) > 1e-9 ||
+		math.Abs(frequencies[2]-frequencies[1]-62.5) > 1e-9 {
+		t.Errorf("expecting neighbouring bins, got %v", frequencies)
+	}
+
+	if count := wrong(run(NewDetector(Config{SampleRate: 8000, Adaptive: true}), samples, 8000)); count == 0 {
+		t.Errorf("expecting the interference to get through without tracking")
+	}
+}
+
+func Test_SetFrequencies(t *testing.T) {
+	detector := NewDetector(Config{SampleRate: 8000})
+	detector.SetFrequencies([]float64{})
+
+	// Still listening on the defaults.
+	if detections := detector.Process(make([]float64, 128)); len(detections) > 0 ||
+		len(detector.Frequencies()) != len(defaultFrequencies) {
+		t.Errorf("expecting the default frequencies to hold, got %v", detector.Frequencies())
+	}
+}
+
+func Test_ProcessBlockChannel(t *testing.T) {
+	// A tone on the second channel only.
+	samples := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 700}).Render([]decode.Detection{
+		{State: false, Duration: 100 * time.Millisecond},
+		{State: true, Duration: 200 * time.Millisecond},
+		{State: false, Duration: 100 * time.Millisecond},
+	})
+	data := make([]byte, 4*len(samples))
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(data[4*i+2:], uint16(sample))
+	}
+
+	for channel, exp := range []int{0, 2} {
+		detector := NewDetector(Config{SampleRate: 8000, Channel: channel})
+		detections := []decode.Detection{}
+		for block := range slices.Chunk(data, 4*80) {
+			detections = append(detections, detector.ProcessBlock(audio.Block{Data: block, Format: audio.FormatS16,
+				Channels: 2, SampleRate: 8000})...)
+		}
+		if len(detections) != exp {
+			t.Errorf("expecting %d detections on channel %d, got %v", exp, channel, detections)
+		}
+	}
+}
+
+func Test_DetectResolution(t *testing.T) {
+	// 16ms at 48kHz, the same bandwidth as 128 samples at 8kHz.
+	detector := NewDetector(Config{SampleRate: 48000})
+	for _, tc := range []struct {
+		frequency float64
+		exp       bool
+	}{{700, true}, {2000, false}} {
+		block := make([]float64, 768)
+		for i := range block {
+			block[i] = 0.5 * math.Sin(2*math.Pi*tc.frequency*float64(i)/48000)
+		}
+		if detected := detector.Detect(block); detected != tc.exp {
+			t.Errorf("expecting detection of %gHz to be %v, got %v", tc.frequency, tc.exp, detected)
+		}
+	}
+	if len(detector.samples) != 768 {
+		t.Errorf("expecting blocks of 768 samples, got %d", len(detector.samples))
+	}
+}
@@ -0,0 +1,144 @@
+package detect
+
+import (
+	"math"
+	"time"
+
+	"github.com/rebay1982/gdsp/fft"
+	"github.com/rebay1982/gdsp/windowing"
+)
+
+// Spectrum Magnitude of the frequencies in blocks of audio, by FFT.
+type Spectrum struct {
+	// Avoid recreating these for every block.
+	samples []float64
+	fspec   []complex128
+	mags    []float64
+}
+
+// NewSpectrum Create a spectrum for blocks of up to size samples, size being a power of two. Each of the size/2 bins is
+// sampleRate/size Hz wide.
+func NewSpectrum(size int) *Spectrum {
+	return &Spectrum{
+		samples: make([]float64, size),
+		fspec:   make([]complex128, size),
+		mags:    make([]float64, size/2),
+	}
+}
+
+// Compute Magnitude of each bin for the block (normalized to [-1, 1]), from DC up to half the sample rate. Shorter
+// blocks are zero padded, longer ones cut. The magnitudes are only valid until the next call.
+func (s *Spectrum) Compute(samples []float64) []float64 {
+	n := copy(s.samples, samples)
+	clear(s.samples[n:])
+
+	// Window (reduces spectral leakage)
+	// Only apply it to the samples, not the padding.
+	windowing.Hann(s.samples[:n])
+
+	for i, sample := range s.samples {
+		s.fspec[i] = complex(sample, 0)
+	}
+	// The size is a power of two, this can't fail.
+	_ = fft.IterativeFFT(s.fspec)
+
+	// Normalize magnitudes and take into account the hanning window that was applied on the input samples before FFT.
+	hannFactorRMS := windowing.HannFactorRMS(n)
+	for i := range s.mags {
+		s.mags[i] = 2.0 * (fft.ComputeMagnitude(s.fspec[i]) / float64(n)) / hannFactorRMS
+	}
+	s.mags[0] /= 2
+
+	return s.mags
+}
+
+// Peak Position of the peak at the bin of a power spectrum, in fractional bins, from the parabola through the peak and
+// its neighbours (in dB).
+func Peak(power []float64, bin int) float64 {
+	if bin <= 0 || bin >= len(power)-1 {
+		return float64(bin)
+	}
+
+	a := 10 * math.Log10(max(power[bin-1], 1e-20))
+	b := 10 * math.Log10(max(power[bin], 1e-20))
+	c := 10 * math.Log10(max(power[bin+1], 1e-20))
+	if d := a - 2*b + c; d < 0 {
+		return float64(bin) + 0.5*(a-c)/d
+	}
+	return float64(bin)
+}
+
+// AveragedSpectrum Power of the frequencies in audio averaged over time, by FFT of every size samples.
+type AveragedSpectrum struct {
+	sampleRate int
+	average    time.Duration
+	spectrum   *Spectrum
+
+	// Samples waiting for a full FFT.
+	buffer []float64
+	filled int
+
+	power    []float64
+	averaged time.Duration
+}
+
+// NewAveragedSpectrum Create a spectrum averaged over the time constant, for FFTs of size samples (a power of two).
+func NewAveragedSpectrum(sampleRate, size int, average time.Duration) *AveragedSpectrum {
+	return &AveragedSpectrum{
+		sampleRate: sampleRate,
+		average:    average,
+		spectrum:   NewSpectrum(size),
+		buffer:     make([]float64, size),
+		power:      make([]float64, size/2),
+	}
+}
+
+// Process Take in the next samples (normalized to [-1, 1]), and tell whether the spectrum was updated.
+func (a *AveragedSpectrum) Process(samples []float64) bool {
+	updated := false
+	for len(samples) > 0 {
+		n := copy(a.buffer[a.filled:], samples)
+		a.filled += n
+		samples = samples[n:]
+
+		if a.filled == len(a.buffer) {
+			a.update()
+			a.filled = 0
+			updated = true
+		}
+	}
+	return updated
+}
+
+func (a *AveragedSpectrum) update() {
+	duration := time.Duration(float64(len(a.buffer)) / float64(a.sampleRate) * float64(time.Second))
+	weight := 1 - math.Exp(-duration.Seconds()/a.average.Seconds())
+	if a.averaged == 0 {
+		weight = 1
+	}
+	for i, mag := range a.spectrum.Compute(a.buffer) {
+		a.power[i] += weight * (mag*mag - a.power[i])
+	}
+	a.averaged += duration
+}
+
+// Power Averaged power of each bin, from DC up to half the sample rate.
+func (a *AveragedSpectrum) Power() []float64 {
+	return a.power
+}
+
+// Averaged Time of audio averaged so far.
+func (a *AveragedSpectrum) Averaged() time.Duration {
+	return a.averaged
+}
+
+// Bin Bin of a frequency, within the spectrum.
+func (a *AveragedSpectrum) Bin(frequency float64) int {
+	bin := int(math.Round(frequency * float64(len(a.buffer)) / float64(a.sampleRate)))
+	return min(max(bin, 0), len(a.power)-1)
+}
+
+// Frequency Frequency at a (fractional) bin.
+func (a *AveragedSpectrum) Frequency(bin float64) float64 {
+	return bin * float64(a.sampleRate) / float64(len(a.buffer))
+}
@@ -0,0 +1,25 @@
+package detect
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func Test_SpectrumCompute(t *testing.T) {
+	// 1000Hz falls on bin 32 of 256 at 8kHz, whether the block fills the FFT or not.
+	for _, length := range []int{256, 200} {
+		block := make([]float64, length)
+		for i := range block {
+			block[i] = 0.5 * math.Sin(2*math.Pi*1000*float64(i)/8000)
+		}
+
+		mags := NewSpectrum(256).Compute(block)
+		if len(mags) != 128 {
+			t.Fatalf("expecting 128 bins, got %d", len(mags))
+		}
+		if peak := slices.Index(mags, slices.Max(mags)); peak != 32 {
+			t.Errorf("expecting the peak at bin 32 for %d samples, got %d", length, peak)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package detect
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	defaultMinFrequency = 300.0
+	defaultMaxFrequency = 1200.0
+	defaultTrackerSize  = 1024
+	defaultAcquisition  = time.Second
+	defaultTrackerSNR   = 10.0
+	defaultDrift        = 50.0
+)
+
+// TrackerConfig Where to look for a carrier and how closely to follow it.
+type TrackerConfig struct {
+	SampleRate int
+
+	// MinFrequency Lowest frequency a carrier is looked for at, defaults to 300Hz.
+	MinFrequency float64
+	// MaxFrequency Highest frequency a carrier is looked for at, defaults to 1200Hz.
+	MaxFrequency float64
+	// Size Samples per FFT, a power of two. Defaults to 1024, about 8Hz per bin at 8kHz.
+	Size int
+
+	// Acquisition Time the spectrum is averaged over. A carrier has to be keyed over about that long to be found, so that
+	// noise and static crashes aren't. Defaults to 1s.
+	Acquisition time.Duration
+	// MinSNR dB the carrier has to stand above the rest of the band (its median) to be found, defaults to 10dB.
+	MinSNR float64
+	// Drift Hz on either side of the carrier it is followed within once found, defaults to 50Hz. Stronger carriers
+	// further away are left alone.
+	Drift float64
+}
+
+// Tracker Finds the strongest carrier that keeps coming back in the audio, and follows it as it drifts.
+type Tracker struct {
+	config   TrackerConfig
+	spectrum *AveragedSpectrum
+
+	locked    bool
+	frequency float64
+}
+
+// NewTracker Create a tracker.
+func NewTracker(cfg TrackerConfig) *Tracker {
+	if cfg.MinFrequency <= 0 {
+		cfg.MinFrequency = defaultMinFrequency
+	}
+	if cfg.MaxFrequency <= 0 {
+		cfg.MaxFrequency = defaultMaxFrequency
+	}
+	if cfg.Size <= 0 {
+		cfg.Size = defaultTrackerSize
+	}
+	if cfg.Acquisition <= 0 {
+		cfg.Acquisition = defaultAcquisition
+	}
+	if cfg.MinSNR <= 0 {
+		cfg.MinSNR = defaultTrackerSNR
+	}
+	if cfg.Drift <= 0 {
+		cfg.Drift = defaultDrift
+	}
+
+	return &Tracker{
+		config:   cfg,
+		spectrum: NewAveragedSpectrum(cfg.SampleRate, cfg.Size, cfg.Acquisition),
+	}
+}
+
+// Process Look at the next samples (normalized to [-1, 1]). The spectrum is updated every Size samples.
+func (t *Tracker) Process(samples []float64) {
+	if t.spectrum.Process(samples) {
+		t.update()
+	}
+}
+
+// Frequency Frequency of the carrier, if one was found.
+func (t *Tracker) Frequency() (float64, bool) {
+	return t.frequency, t.locked
+}
+
+// update Look for the carrier in the spectrum, over the whole band until one is found and around it afterwards.
+func (t *Tracker) update() {
+	if t.spectrum.Averaged() < t.config.Acquisition {
+		return
+	}
+
+	power := t.spectrum.Power()
+	first, last := t.spectrum.Bin(t.config.MinFrequency), t.spectrum.Bin(t.config.MaxFrequency)
+	band := power[first : last+1]
+
+	low, high := first, last
+	if t.locked {
+		low = max(first, t.spectrum.Bin(t.frequency-t.config.Drift))
+		high = min(last, t.spectrum.Bin(t.frequency+t.config.Drift))
+	}
+	peak := low
+	for i := low; i <= high; i++ {
+		if power[i] > power[peak] {
+			peak = i
+		}
+	}
+
+	// Against the median, which the carrier and its sidebands hardly move. Keep the last frequency while the carrier
+	// is gone.
+	median := slices.Clone(band)
+	slices.Sort(median)
+	if 10*math.Log10(power[peak]/max(median[len(median)/2], 1e-20)) < t.config.MinSNR {
+		return
+	}
+
+	t.locked = true
+	t.frequency = t.spectrum.Frequency(Peak(power, peak))
+}
@@ -0,0 +1,112 @@
+package detect
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/decode"
+	"github.com/rebay1982/gmorse/internal/synth"
+)
+
+// track Feed the samples to the tracker in 10ms blocks, returning the frequency after each second.
+func track(tracker *Tracker, samples []int16, sampleRate int) []float64 {
+	frames := sampleRate / 100
+
+	frequencies := []float64{}
+	block := make([]float64, frames)
+	for i := 0; i+frames <= len(samples); i += frames {
+		for j := range block {
+			block[j] = float64(samples[i+j]) / math.MaxInt16
+		}
+		tracker.Process(block)
+
+		if (i/frames+1)%100 == 0 {
+			frequency, _ := tracker.Frequency()
+			frequencies = append(frequencies, frequency)
+		}
+	}
+	return frequencies
+}
+
+// keyed Dits and dahs over the duration.
+func keyed(duration time.Duration) []decode.Detection {
+	keying := []decode.Detection{}
+	for total := time.Duration(0); total < duration; total += 480 * time.Millisecond {
+		keying = append(keying,
+			decode.Detection{State: true, Duration: 60 * time.Millisecond},
+			decode.Detection{State: false, Duration: 60 * time.Millisecond},
+			decode.Detection{State: true, Duration: 180 * time.Millisecond},
+			decode.Detection{State: false, Duration: 180 * time.Millisecond},
+		)
+	}
+	return keying
+}
+
+func Test_TrackerAcquire(t *testing.T) {
+	samples := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 723}).Render(keyed(3 * time.Second))
+	samples = synth.NewChannel(synth.ChannelConfig{
+		SampleRate: 8000,
+		SNR:        0,
+		Noise:      true,
+		// Weaker carrier close by.
+		Interferers: []synth.Interferer{{Frequency: 1000, Level: 0.3}},
+		Seed:        1,
+	}).Apply(samples)
+
+	tracker := NewTracker(TrackerConfig{SampleRate: 8000})
+	track(tracker, samples, 8000)
+
+	if frequency, ok := tracker.Frequency(); !ok || math.Abs(frequency-723) > 3 {
+		t.Errorf("expecting a carrier at 723Hz, got %.1fHz (found: %v)", frequency, ok)
+	}
+}
+
+func Test_TrackerNoise(t *testing.T) {
+	// The noise is set against a tone at the end, only listen up to it.
+	samples := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 700}).Render([]decode.Detection{
+		{State: false, Duration: 5 * time.Second},
+		{State: true, Duration: 60 * time.Millisecond},
+	})
+	samples = synth.NewChannel(synth.ChannelConfig{
+		SampleRate: 8000,
+		SNR:        0,
+		Noise:      true,
+		// Static crashes come and go, they aren't a carrier either.
+		ImpulseRate:  2,
+		ImpulseLevel: 10,
+		Seed:         1,
+	}).Apply(samples)
+	samples = samples[:5*8000]
+
+	tracker := NewTracker(TrackerConfig{SampleRate: 8000})
+	track(tracker, samples, 8000)
+
+	if frequency, ok := tracker.Frequency(); ok {
+		t.Errorf("expecting no carrier, got %.1fHz", frequency)
+	}
+}
+
+func Test_TrackerDrift(t *testing.T) {
+	// Drifting 40Hz up over 8s.
+	samples := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: 650, Drift: 5}).
+		Render(keyed(8 * time.Second))
+	samples = synth.NewChannel(synth.ChannelConfig{
+		SampleRate: 8000,
+		SNR:        6,
+		Noise:      true,
+		// Stronger carrier further away, to be left alone once locked.
+		Interferers: []synth.Interferer{{Frequency: 1100, Level: 2, Keying: append(
+			[]decode.Detection{{State: false, Duration: 3 * time.Second}}, keyed(5*time.Second)...)}},
+		Seed: 1,
+	}).Apply(samples)
+
+	frequencies := track(NewTracker(TrackerConfig{SampleRate: 8000}), samples, 8000)
+
+	// Lagging behind by about the acquisition time.
+	for i, frequency := range frequencies[1:] {
+		if exp := 650 + 5*float64(i+1); math.Abs(frequency-exp) > 8 {
+			t.Errorf("expecting %.0fHz after %ds, got %.1fHz", exp, i+2, frequency)
+		}
+	}
+}
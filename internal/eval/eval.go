@@ -0,0 +1,165 @@
+package eval
+
+import (
+	"slices"
+	"strings"
+)
+
+// Score Errors made against a reference, as edit operations.
+type Score struct {
+	Substitutions int
+	Deletions     int
+	Insertions    int
+	// Length Size of the reference.
+	Length int
+}
+
+// Errors Total edit operations.
+func (s Score) Errors() int {
+	return s.Substitutions + s.Deletions + s.Insertions
+}
+
+// Rate Errors per reference unit. Can go above 1 when a lot was inserted.
+func (s Score) Rate() float64 {
+	if s.Length == 0 {
+		if s.Errors() == 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(s.Errors()) / float64(s.Length)
+}
+
+// Add Combine the scores, for an overall rate weighted by length.
+func (s Score) Add(other Score) Score {
+	return Score{
+		Substitutions: s.Substitutions + other.Substitutions,
+		Deletions:     s.Deletions + other.Deletions,
+		Insertions:    s.Insertions + other.Insertions,
+		Length:        s.Length + other.Length,
+	}
+}
+
+// CER Character errors of the decoded text against the reference, after normalization. Spaces count as characters.
+func CER(reference, decoded string) Score {
+	return score(align([]rune(Normalize(reference)), []rune(Normalize(decoded))))
+}
+
+// WER Word errors of the decoded text against the reference, after normalization.
+func WER(reference, decoded string) Score {
+	return score(align(strings.Fields(Normalize(reference)), strings.Fields(Normalize(decoded))))
+}
+
+// Normalize Upper case the text, apply the backspaces of retracted words and collapse white space, the way the decoder
+// output reads.
+func Normalize(text string) string {
+	runes := []rune{}
+	for _, r := range text {
+		if r == '\b' {
+			runes = runes[:max(0, len(runes)-1)]
+			continue
+		}
+		runes = append(runes, r)
+	}
+
+	return strings.Join(strings.Fields(strings.ToUpper(string(runes))), " ")
+}
+
+// Diff Decoded text with its errors against the reference marked: [X>Y] for a substitution, [-X] for a character that
+// was missed and [+Y] for one that shouldn't be there.
+func Diff(reference, decoded string) string {
+	b := strings.Builder{}
+	for _, op := range align([]rune(Normalize(reference)), []rune(Normalize(decoded))) {
+		switch op.kind {
+		case match:
+			b.WriteRune(op.decoded)
+		case substitution:
+			b.WriteString("[" + string(op.reference) + ">" + string(op.decoded) + "]")
+		case deletion:
+			b.WriteString("[-" + string(op.reference) + "]")
+		case insertion:
+			b.WriteString("[+" + string(op.decoded) + "]")
+		}
+	}
+	return b.String()
+}
+
+type opKind int
+
+const (
+	match opKind = iota
+	substitution
+	deletion
+	insertion
+)
+
+type op[T comparable] struct {
+	kind      opKind
+	reference T
+	decoded   T
+}
+
+// align Edit operations turning the reference into the decoded sequence, with the fewest edits (Levenshtein).
+func align[T comparable](reference, decoded []T) []op[T] {
+	// costs[i][j] Edits between the first i of the reference and the first j decoded.
+	costs := make([][]int, len(reference)+1)
+	for i := range costs {
+		costs[i] = make([]int, len(decoded)+1)
+		costs[i][0] = i
+	}
+	for j := range costs[0] {
+		costs[0][j] = j
+	}
+
+	for i := 1; i <= len(reference); i++ {
+		for j := 1; j <= len(decoded); j++ {
+			substitution := costs[i-1][j-1]
+			if reference[i-1] != decoded[j-1] {
+				substitution++
+			}
+			costs[i][j] = min(substitution, costs[i-1][j]+1, costs[i][j-1]+1)
+		}
+	}
+
+	// Walk back from the end, preferring matches and substitutions.
+	ops := []op[T]{}
+	i, j := len(reference), len(decoded)
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && reference[i-1] == decoded[j-1] && costs[i][j] == costs[i-1][j-1]:
+			ops = append(ops, op[T]{kind: match, reference: reference[i-1], decoded: decoded[j-1]})
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && costs[i][j] == costs[i-1][j-1]+1:
+			ops = append(ops, op[T]{kind: substitution, reference: reference[i-1], decoded: decoded[j-1]})
+			i, j = i-1, j-1
+		case i > 0 && costs[i][j] == costs[i-1][j]+1:
+			ops = append(ops, op[T]{kind: deletion, reference: reference[i-1]})
+			i--
+		default:
+			ops = append(ops, op[T]{kind: insertion, decoded: decoded[j-1]})
+			j--
+		}
+	}
+
+	slices.Reverse(ops)
+	return ops
+}
+
+func score[T comparable](ops []op[T]) Score {
+	s := Score{}
+	for _, op := range ops {
+		switch op.kind {
+		case match:
+			s.Length++
+		case substitution:
+			s.Substitutions++
+			s.Length++
+		case deletion:
+			s.Deletions++
+			s.Length++
+		case insertion:
+			s.Insertions++
+		}
+	}
+	return s
+}
@@ -0,0 +1,86 @@
+package eval
+
+import "testing"
+
+func Test_CER(t *testing.T) {
+	testCases := []struct {
+		name      string
+		reference string
+		decoded   string
+		exp       Score
+		diff      string
+	}{
+		{name: "exact", reference: "cq de w1aw", decoded: "CQ DE W1AW", exp: Score{Length: 10}, diff: "CQ DE W1AW"},
+		{name: "white_space", reference: "CQ  DE\nW1AW ", decoded: " CQ DE W1AW", exp: Score{Length: 10}, diff: "CQ DE W1AW"},
+		{
+			name:      "substitution",
+			reference: "CQ DE W1AW",
+			decoded:   "CQ TE W1AW",
+			exp:       Score{Substitutions: 1, Length: 10},
+			diff:      "CQ [D>T]E W1AW",
+		},
+		{
+			name:      "split_character",
+			reference: "CQ DE W1AW",
+			decoded:   "CQ DE W1ETW",
+			exp:       Score{Substitutions: 1, Insertions: 1, Length: 10},
+			diff:      "CQ DE W1[+E][A>T]W",
+		},
+		{
+			name:      "missed_word_gap",
+			reference: "CQ DE W1AW",
+			decoded:   "CQDE W1AW",
+			exp:       Score{Deletions: 1, Length: 10},
+			diff:      "CQ[- ]DE W1AW",
+		},
+		{name: "retracted", reference: "TEST", decoded: "TESR\b\b\b\bTEST", exp: Score{Length: 4}, diff: "TEST"},
+		{name: "nothing_decoded", reference: "CQ", decoded: "", exp: Score{Deletions: 2, Length: 2}, diff: "[-C][-Q]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if s := CER(tc.reference, tc.decoded); s != tc.exp {
+				t.Errorf("expecting %+v, got %+v", tc.exp, s)
+			}
+			if diff := Diff(tc.reference, tc.decoded); diff != tc.diff {
+				t.Errorf("expecting diff [%s], got [%s]", tc.diff, diff)
+			}
+		})
+	}
+}
+
+func Test_WER(t *testing.T) {
+	s := WER("CQ CQ DE W1AW K", "CQ CQ TE W1AW W1AW K")
+	if exp := (Score{Substitutions: 1, Insertions: 1, Length: 5}); s != exp {
+		t.Errorf("expecting %+v, got %+v", exp, s)
+	}
+	if rate := s.Rate(); rate != 0.4 {
+		t.Errorf("expecting a rate of 0.4, got %.2f", rate)
+	}
+}
+
+func Test_Breakdown(t *testing.T) {
+	snr := func(v float64) Labels { return Labels{SNR: &v} }
+
+	results := []Result{
+		Evaluate("a", "CQ DE W1AW", "CQ DE W1AW", snr(10)),
+		Evaluate("b", "CQ DE W1AW", "CQ TE W1AW", snr(0)),
+		Evaluate("c", "CQ DE W1AW", "CQ TE W1EW", snr(0)),
+		Evaluate("d", "CQ", "CQ", Labels{}),
+	}
+
+	groups := Breakdown(results, BySNR)
+	if len(groups) != 2 || groups[0].Value != 0 || groups[1].Value != 10 {
+		t.Fatalf("expecting groups for 0 and 10 dB, got %+v", groups)
+	}
+	if groups[0].Files != 2 || groups[0].CER.Errors() != 3 || groups[0].CER.Length != 20 {
+		t.Errorf("expecting 3 errors over 20 characters at 0 dB, got %+v", groups[0])
+	}
+	if groups[1].CER.Errors() != 0 {
+		t.Errorf("expecting no errors at 10 dB, got %+v", groups[1])
+	}
+
+	if total := Total(results); total.Files != 4 || total.CER.Length != 32 || total.WER.Errors() != 3 {
+		t.Errorf("expecting 4 files, 32 characters and 3 word errors, got %+v", total)
+	}
+}
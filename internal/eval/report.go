@@ -0,0 +1,95 @@
+package eval
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Labels What is known about a recording, for breaking down the results.
+type Labels struct {
+	Wpm *float64 `json:"wpm,omitempty"`
+	SNR *float64 `json:"snr,omitempty"`
+}
+
+// Result Decoding of a recording scored against its transcript.
+type Result struct {
+	Name      string
+	Reference string
+	Decoded   string
+	Labels    Labels
+
+	CER Score
+	WER Score
+}
+
+// Evaluate Score the decoded text of a recording against its transcript.
+func Evaluate(name, reference, decoded string, labels Labels) Result {
+	return Result{
+		Name:      name,
+		Reference: reference,
+		Decoded:   decoded,
+		Labels:    labels,
+		CER:       CER(reference, decoded),
+		WER:       WER(reference, decoded),
+	}
+}
+
+// Group Results sharing the same value of a label, scored together.
+type Group struct {
+	Value float64
+	Files int
+	CER   Score
+	WER   Score
+}
+
+// Total Overall scores of the results.
+func Total(results []Result) Group {
+	total := Group{}
+	for _, r := range results {
+		total.add(r)
+	}
+	return total
+}
+
+// Breakdown Group the results by the label picked by the key, in increasing order. Results without the label are left
+// out.
+func Breakdown(results []Result, key func(Labels) *float64) []Group {
+	groups := map[float64]*Group{}
+	for _, r := range results {
+		value := key(r.Labels)
+		if value == nil {
+			continue
+		}
+
+		g, ok := groups[*value]
+		if !ok {
+			g = &Group{Value: *value}
+			groups[*value] = g
+		}
+		g.add(r)
+	}
+
+	breakdown := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		breakdown = append(breakdown, *g)
+	}
+	slices.SortFunc(breakdown, func(a, b Group) int { return cmp.Compare(a.Value, b.Value) })
+
+	return breakdown
+}
+
+// ByWpm Key grouping results by sending speed.
+func ByWpm(l Labels) *float64 {
+	return l.Wpm
+}
+
+// BySNR Key grouping results by signal to noise ratio.
+func BySNR(l Labels) *float64 {
+	return l.SNR
+}
+
+func (g *Group) add(r Result) {
+	g.Files++
+	g.CER = g.CER.Add(r.CER)
+	g.WER = g.WER.Add(r.WER)
+}
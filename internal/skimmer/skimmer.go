@@ -0,0 +1,358 @@
+package skimmer
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/decode"
+	"github.com/rebay1982/gmorse/internal/detect"
+)
+
+const (
+	defaultMinFrequency = 200.0
+	defaultMaxFrequency = 3400.0
+	defaultSize         = 1024
+	defaultAcquisition  = time.Second
+	defaultMinSNR       = 10.0
+	defaultSpacing      = 150.0
+	defaultRetire       = 10 * time.Second
+	defaultMaxChannels  = 32
+	defaultBlock        = 20 * time.Millisecond
+	// A single frequency per channel varies more in noise than the strongest of a few, as by default, so it takes more.
+	defaultDetectorSNR = 12.0
+)
+
+// Config What part of the audio to skim and how to detect and decode each signal.
+type Config struct {
+	SampleRate int
+
+	// MinFrequency Lowest frequency a carrier is looked for at, defaults to 200Hz.
+	MinFrequency float64
+	// MaxFrequency Highest frequency a carrier is looked for at, defaults to 3400Hz.
+	MaxFrequency float64
+	// Size Samples per FFT, a power of two. Defaults to 1024, about 8Hz per bin at 8kHz.
+	Size int
+	// Acquisition Time the spectrum is averaged over. A carrier has to be keyed over about that long to get a channel,
+	// the audio of that time is decoded once it does. Defaults to 1s.
+	Acquisition time.Duration
+	// MinSNR dB a carrier has to stand above the rest of the band (its median) to get a channel, defaults to 10dB.
+	MinSNR float64
+	// Spacing Hz between two carriers for them to get a channel each, defaults to 150Hz. Closer carriers are decoded as
+	// one.
+	Spacing float64
+	// Retire Time a channel is kept after its carrier was last keyed, defaults to 10s.
+	Retire time.Duration
+	// MaxChannels Channels decoded at once, defaults to 32. The weakest carriers wait for a channel to be retired.
+	MaxChannels int
+
+	// Block Audio the detectors look at at once, defaults to 20ms. Longer blocks tell closer carriers apart, shorter ones
+	// keep up with faster keying.
+	Block time.Duration
+	// Detector How the tone is detected on each channel, the sample rate, frequencies and block size being set by the
+	// skimmer. Detection is always adaptive, the signals come in at every level. MinSNR defaults to 12dB.
+	Detector detect.Config
+	// Decoder How each channel is decoded.
+	Decoder decode.DecoderConfig
+}
+
+// Event Something that happened while decoding one of the signals.
+type Event struct {
+	// Channel Identifies the signal, channels are numbered from 1 as they are created.
+	Channel int
+	// Frequency Audio frequency of the carrier when the event was decoded.
+	Frequency float64
+
+	// Start and End are offsets from the start of skimming.
+	decode.Event
+}
+
+// Channel A carrier being decoded.
+type Channel struct {
+	ID        int
+	Frequency float64
+	// SNR dB between the tone and the noise floor on the channel.
+	SNR float64
+	// Wpm Speed of the sender.
+	Wpm float64
+}
+
+type channel struct {
+	id        int
+	frequency float64
+	detector  *detect.Detector
+	decoder   *decode.MorseDecoder
+
+	// Sample position the channel started at, and when its carrier was last keyed.
+	start    int
+	lastSeen int
+}
+
+// Skimmer Finds every carrier in the audio and decodes each one on its own channel, creating channels as signals appear
+// and retiring them as they vanish.
+type Skimmer struct {
+	config   Config
+	spectrum *detect.AveragedSpectrum
+
+	channels []*channel
+	nextID   int
+
+	// Samples received so far, those waiting for a full block, and the latest ones for new channels to start on.
+	position int
+	block    []float64
+	history  []float64
+}
+
+// NewSkimmer Create a skimmer. Panics if the decoder config doesn't validate, rather than once the first channel opens.
+func NewSkimmer(cfg Config) *Skimmer {
+	if err := cfg.Decoder.Validate(); err != nil {
+		panic(err)
+	}
+	if cfg.MinFrequency <= 0 {
+		cfg.MinFrequency = defaultMinFrequency
+	}
+	if cfg.MaxFrequency <= 0 {
+		cfg.MaxFrequency = defaultMaxFrequency
+	}
+	if cfg.Size <= 0 {
+		cfg.Size = defaultSize
+	}
+	if cfg.Acquisition <= 0 {
+		cfg.Acquisition = defaultAcquisition
+	}
+	if cfg.MinSNR <= 0 {
+		cfg.MinSNR = defaultMinSNR
+	}
+	if cfg.Spacing <= 0 {
+		cfg.Spacing = defaultSpacing
+	}
+	if cfg.Retire <= 0 {
+		cfg.Retire = defaultRetire
+	}
+	if cfg.MaxChannels <= 0 {
+		cfg.MaxChannels = defaultMaxChannels
+	}
+	if cfg.Block <= 0 {
+		cfg.Block = defaultBlock
+	}
+	if cfg.Detector.MinSNR <= 0 {
+		cfg.Detector.MinSNR = defaultDetectorSNR
+	}
+
+	return &Skimmer{
+		config:   cfg,
+		spectrum: detect.NewAveragedSpectrum(cfg.SampleRate, cfg.Size, cfg.Acquisition),
+		nextID:   1,
+	}
+}
+
+// Process Look at the next samples (normalized to [-1, 1]), returning what was decoded on every channel.
+func (s *Skimmer) Process(samples []float64) []Event {
+	events := []Event{}
+	size := s.samples(s.config.Block)
+
+	for len(samples) > 0 {
+		n := min(size-len(s.block), len(samples))
+		s.block = append(s.block, samples[:n]...)
+		samples = samples[n:]
+		if len(s.block) < size {
+			break
+		}
+
+		for _, c := range s.channels {
+			events = append(events, s.detect(c, s.block)...)
+		}
+		s.position += len(s.block)
+
+		// Keep the audio of the acquisition time, for new channels to start with.
+		s.history = append(s.history, s.block...)
+		if excess := len(s.history) - s.samples(s.config.Acquisition) - s.config.Size; excess > 0 {
+			s.history = s.history[excess:]
+		}
+
+		if s.spectrum.Process(s.block) {
+			events = append(events, s.update()...)
+		}
+		s.block = s.block[:0]
+	}
+
+	return events
+}
+
+// Flush Return what is left to decode on every channel, e.g. at the end of a recording.
+func (s *Skimmer) Flush() []Event {
+	events := []Event{}
+	for _, c := range s.channels {
+		events = append(events, s.flush(c)...)
+	}
+	return events
+}
+
+// Channels Carriers being decoded, from the lowest frequency up.
+func (s *Skimmer) Channels() []Channel {
+	channels := []Channel{}
+	for _, c := range s.channels {
+		channels = append(channels, Channel{ID: c.id, Frequency: c.frequency, SNR: c.detector.SNR(), Wpm: c.decoder.Wpm()})
+	}
+	return channels
+}
+
+// update Follow the carriers of the channels in the spectrum, retire channels whose carrier is gone and create those
+// for new carriers. The average lingers for a while after a strong carrier is gone, channels are retired and created
+// on keying instead.
+func (s *Skimmer) update() []Event {
+	if s.spectrum.Averaged() < s.config.Acquisition {
+		return nil
+	}
+
+	events := []Event{}
+	peaks := s.peaks()
+
+	// Each channel follows the closest carrier within half the spacing.
+	taken := make([]bool, len(peaks))
+	for _, c := range s.channels {
+		closest := -1
+		for i, peak := range peaks {
+			if !taken[i] && math.Abs(peak-c.frequency) < s.config.Spacing/2 &&
+				(closest < 0 || math.Abs(peak-c.frequency) < math.Abs(peaks[closest]-c.frequency)) {
+				closest = i
+			}
+		}
+		if closest >= 0 {
+			taken[closest] = true
+			c.frequency = peaks[closest]
+			c.detector.SetFrequencies([]float64{c.frequency})
+		}
+	}
+
+	s.channels = slices.DeleteFunc(s.channels, func(c *channel) bool {
+		if s.duration(s.position-c.lastSeen) < s.config.Retire {
+			return false
+		}
+		events = append(events, s.flush(c)...)
+		return true
+	})
+
+	for i, peak := range peaks {
+		if taken[i] || len(s.channels) >= s.config.MaxChannels || s.near(peak) {
+			continue
+		}
+		events = append(events, s.open(peak)...)
+	}
+	slices.SortFunc(s.channels, func(a, b *channel) int {
+		return cmp.Compare(a.frequency, b.frequency)
+	})
+
+	return events
+}
+
+// peaks Frequency of the carriers standing out of the band, strongest first, at least Spacing apart.
+func (s *Skimmer) peaks() []float64 {
+	power := s.spectrum.Power()
+	first, last := s.spectrum.Bin(s.config.MinFrequency), s.spectrum.Bin(s.config.MaxFrequency)
+
+	// Against the median, which the carriers and their sidebands hardly move.
+	median := slices.Clone(power[first : last+1])
+	slices.Sort(median)
+	threshold := median[len(median)/2] * math.Pow(10, s.config.MinSNR/10)
+
+	bins := []int{}
+	for i := max(first, 1); i <= min(last, len(power)-2); i++ {
+		if power[i] > threshold && power[i] >= power[i-1] && power[i] > power[i+1] {
+			bins = append(bins, i)
+		}
+	}
+	slices.SortFunc(bins, func(a, b int) int {
+		return cmp.Compare(power[b], power[a])
+	})
+
+	peaks := []float64{}
+	for _, bin := range bins {
+		frequency := s.spectrum.Frequency(detect.Peak(power, bin))
+		if !slices.ContainsFunc(peaks, func(p float64) bool { return math.Abs(p-frequency) < s.config.Spacing }) {
+			peaks = append(peaks, frequency)
+		}
+	}
+	return peaks
+}
+
+// near Whether a channel is already listening close to the frequency.
+func (s *Skimmer) near(frequency float64) bool {
+	return slices.ContainsFunc(s.channels, func(c *channel) bool {
+		return math.Abs(c.frequency-frequency) < s.config.Spacing
+	})
+}
+
+// open Create a channel on the carrier if it was keyed in the audio kept, returning what was decoded in it.
+func (s *Skimmer) open(frequency float64) []Event {
+	config := s.config.Detector
+	config.SampleRate = s.config.SampleRate
+	config.Frequencies = []float64{frequency}
+	config.Adaptive = true
+	config.Track = false
+	config.BlockSize = 1
+	for config.BlockSize < s.samples(s.config.Block) {
+		config.BlockSize *= 2
+	}
+
+	c := &channel{
+		id:        s.nextID,
+		frequency: frequency,
+		detector:  detect.NewDetector(config),
+		decoder:   decode.NewDecoder(s.config.Decoder),
+		start:     s.position - len(s.history),
+		lastSeen:  -1,
+	}
+
+	events := []Event{}
+	for block := range slices.Chunk(s.history, s.samples(s.config.Block)) {
+		events = append(events, s.detect(c, block)...)
+	}
+	if c.lastSeen < 0 {
+		return nil
+	}
+
+	s.nextID++
+	s.channels = append(s.channels, c)
+	return events
+}
+
+// detect Run a block through the detector and decoder of the channel.
+func (s *Skimmer) detect(c *channel, block []float64) []Event {
+	detections := c.detector.Process(block)
+	if slices.ContainsFunc(detections, func(d decode.Detection) bool { return d.State }) {
+		c.lastSeen = s.position
+	}
+	return s.tag(c, c.decoder.Decode(detections))
+}
+
+// flush Return what is left to decode on the channel.
+func (s *Skimmer) flush(c *channel) []Event {
+	events := []Event{}
+	if detection, ok := c.detector.Flush(); ok {
+		events = append(events, s.tag(c, c.decoder.Decode([]decode.Detection{detection}))...)
+	}
+	return append(events, s.tag(c, c.decoder.Flush())...)
+}
+
+// tag Put the channel on the events, with times from the start of skimming.
+func (s *Skimmer) tag(c *channel, events []decode.Event) []Event {
+	tagged := make([]Event, len(events))
+	for i, e := range events {
+		e.Start += s.duration(c.start)
+		e.End += s.duration(c.start)
+		tagged[i] = Event{Channel: c.id, Frequency: c.frequency, Event: e}
+	}
+	return tagged
+}
+
+// samples Number of samples over a duration.
+func (s *Skimmer) samples(d time.Duration) int {
+	return int(d.Seconds() * float64(s.config.SampleRate))
+}
+
+// duration Time taken by a number of samples.
+func (s *Skimmer) duration(samples int) time.Duration {
+	return time.Duration(float64(samples) / float64(s.config.SampleRate) * float64(time.Second))
+}
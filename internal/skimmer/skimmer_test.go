@@ -0,0 +1,128 @@
+package skimmer
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/decode"
+	"github.com/rebay1982/gmorse/internal/synth"
+)
+
+// signal A station sending text at a speed and pitch, after a delay.
+type signal struct {
+	text      string
+	wpm       int
+	frequency float64
+	delay     time.Duration
+}
+
+// band Audio of the stations sending at once, with some noise.
+func band(t *testing.T, duration time.Duration, signals ...signal) []float64 {
+	mix := make([]float64, int(duration.Seconds()*8000))
+	for _, s := range signals {
+		detections, err := decode.NewEncoder(decode.EncoderConfig{Wpm: s.wpm}).Encode(s.text)
+		if err != nil {
+			t.Fatalf("expecting no error, got %v", err)
+		}
+
+		synthesizer := synth.NewSynthesizer(synth.Config{SampleRate: 8000, Frequency: s.frequency, Amplitude: 0.2})
+		samples := append(synthesizer.Silence(s.delay), synthesizer.Render(detections)...)
+		for i := range min(len(mix), len(samples)) {
+			mix[i] += float64(samples[i])
+		}
+	}
+
+	samples := make([]int16, len(mix))
+	for i := range mix {
+		samples[i] = int16(mix[i])
+	}
+	samples = synth.NewChannel(synth.ChannelConfig{SampleRate: 8000, SNR: 20, Noise: true, Seed: 1}).Apply(samples)
+
+	normalized := make([]float64, len(samples))
+	for i := range samples {
+		normalized[i] = float64(samples[i]) / math.MaxInt16
+	}
+	return normalized
+}
+
+// skim Run the audio through the skimmer in 10ms periods, returning the events of each channel.
+func skim(skimmer *Skimmer, samples []float64) map[int][]Event {
+	events := []Event{}
+	for period := range slices.Chunk(samples, 80) {
+		events = append(events, skimmer.Process(period)...)
+	}
+	events = append(events, skimmer.Flush()...)
+
+	channels := map[int][]Event{}
+	for _, e := range events {
+		channels[e.Channel] = append(channels[e.Channel], e)
+	}
+	return channels
+}
+
+func text(events []Event) string {
+	decoded := make([]decode.Event, len(events))
+	for i, e := range events {
+		decoded[i] = e.Event
+	}
+	return strings.TrimSpace(decode.Text(decoded))
+}
+
+func Test_Skim(t *testing.T) {
+	signals := []signal{
+		{text: "CQ TEST DE W1AW W1AW", wpm: 25, frequency: 650, delay: 500 * time.Millisecond},
+		{text: "TU 5NN 05", wpm: 30, frequency: 1130, delay: 2 * time.Second},
+		{text: "VE2XYZ VE2XYZ", wpm: 18, frequency: 1900, delay: time.Second},
+	}
+	skimmer := NewSkimmer(Config{SampleRate: 8000, Decoder: decode.DecoderConfig{Wpm: 25, Tolerace: 0.4, Adaptive: true}})
+	channels := skim(skimmer, band(t, 14*time.Second, signals...))
+
+	if len(channels) != len(signals) {
+		t.Fatalf("expecting %d channels, got %v", len(signals), channels)
+	}
+	for _, s := range signals {
+		found := false
+		for _, events := range channels {
+			if math.Abs(events[0].Frequency-s.frequency) < 5 {
+				found = true
+				if decoded := text(events); decoded != s.text {
+					t.Errorf("expecting [%s] at %gHz, got [%s]", s.text, s.frequency, decoded)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("expecting a channel at %gHz, got %v", s.frequency, skimmer.Channels())
+		}
+	}
+}
+
+func Test_SkimRetire(t *testing.T) {
+	// One station after the other on the same frequency, with a long pause in between.
+	skimmer := NewSkimmer(Config{SampleRate: 8000, Retire: 3 * time.Second,
+		Decoder: decode.DecoderConfig{Wpm: 20, Tolerace: 0.4}})
+	samples := band(t, 12*time.Second,
+		signal{text: "TEST", wpm: 20, frequency: 800, delay: 500 * time.Millisecond},
+		signal{text: "OK", wpm: 20, frequency: 800, delay: 9 * time.Second},
+	)
+
+	channels := skim(skimmer, samples[:8*8000])
+	if len(skimmer.Channels()) != 0 {
+		t.Errorf("expecting the channel retired, got %v", skimmer.Channels())
+	}
+	if decoded := text(channels[1]); len(channels) != 1 || decoded != "TEST" {
+		t.Errorf("expecting [TEST] on channel 1, got %v", channels)
+	}
+
+	channels = skim(skimmer, samples[8*8000:])
+	if decoded := text(channels[2]); len(channels) != 1 || decoded != "OK" {
+		t.Errorf("expecting [OK] on a new channel, got %v", channels)
+	}
+
+	// Times are from the start of skimming.
+	if events := channels[2]; len(events) == 0 || (events[0].Start-9*time.Second).Abs() > 50*time.Millisecond {
+		t.Errorf("expecting the second station to start at 9s, got %v", events)
+	}
+}
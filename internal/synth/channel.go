@@ -0,0 +1,169 @@
+package synth
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/decode"
+)
+
+const (
+	// Bandwidth the SNR is given in, the usual reference for HF communications.
+	snrBandwidth = 2500.0
+
+	defaultImpulseDecay = time.Millisecond
+)
+
+// ChannelConfig Impairments added to a clean signal. Everything left at zero is turned off.
+type ChannelConfig struct {
+	SampleRate int
+
+	// SNR Signal to noise ratio in dB, the signal being the peak power of the tone and the noise being white gaussian
+	// noise measured over 2500Hz. Noise is only added when Noise is set.
+	SNR   float64
+	Noise bool
+
+	// FadingRate How fast the signal fades in and out (QSB), as the Doppler spread in Hz. Fading is Rayleigh distributed
+	// unless part of the power comes through a steady path.
+	FadingRate float64
+	// FadingSteady Fraction of the signal power coming through a path that doesn't fade, from 0 (Rayleigh) to 1 (no
+	// fading).
+	FadingSteady float64
+
+	// Interferers Other signals close by (QRM).
+	Interferers []Interferer
+
+	// ImpulseRate Average number of static crashes (QRN) per second, each one a burst of noise decaying over
+	// ImpulseDecay (1ms by default). ImpulseLevel gives their peak relative to the peak of the signal.
+	ImpulseRate  float64
+	ImpulseLevel float64
+	ImpulseDecay time.Duration
+
+	// Seed Seed for the noise, fading and impulses, the same seed gives the same audio.
+	Seed uint64
+}
+
+// Interferer A carrier on another frequency, keyed when Keying is set.
+type Interferer struct {
+	Frequency float64
+	// Level Peak relative to the peak of the signal.
+	Level  float64
+	Keying []decode.Detection
+}
+
+// Channel Degrades clean audio as a radio channel would.
+type Channel struct {
+	config ChannelConfig
+	rand   *rand.Rand
+}
+
+// NewChannel Create a channel.
+func NewChannel(cfg ChannelConfig) *Channel {
+	if cfg.ImpulseDecay <= 0 {
+		cfg.ImpulseDecay = defaultImpulseDecay
+	}
+
+	return &Channel{
+		config: cfg,
+		rand:   rand.New(rand.NewPCG(cfg.Seed, cfg.Seed)),
+	}
+}
+
+// Apply Return the samples as received through the channel. The signal level is taken from the loudest sample, so the
+// samples should hold the whole signal rather than a piece of it. Anything going past full scale is clipped.
+func (c *Channel) Apply(samples []int16) []int16 {
+	peak := 0.0
+	signal := make([]float64, len(samples))
+	for i, s := range samples {
+		signal[i] = float64(s)
+		peak = max(peak, math.Abs(signal[i]))
+	}
+
+	if c.config.FadingRate > 0 {
+		c.fade(signal)
+	}
+	for _, interferer := range c.config.Interferers {
+		c.interfere(signal, interferer, peak)
+	}
+	if c.config.ImpulseRate > 0 {
+		c.crash(signal, peak)
+	}
+	if c.config.Noise {
+		c.noise(signal, peak)
+	}
+
+	received := make([]int16, len(signal))
+	for i, s := range signal {
+		received[i] = int16(math.Round(max(math.MinInt16, min(math.MaxInt16, s))))
+	}
+	return received
+}
+
+// fade Scale the signal by the envelope of a complex gaussian process low-pass filtered to the fading rate, the way a
+// Watterson channel path fades a steady tone.
+func (c *Channel) fade(signal []float64) {
+	a := math.Exp(-2 * math.Pi * c.config.FadingRate / float64(c.config.SampleRate))
+	// Bring the filtered noise back to unit power.
+	gain := math.Sqrt((1 + a) / (1 - a))
+
+	steady := math.Sqrt(c.config.FadingSteady)
+	scattered := math.Sqrt((1 - c.config.FadingSteady) / 2)
+
+	var re, im float64
+	for i := range signal {
+		re = a*re + (1-a)*c.rand.NormFloat64()
+		im = a*im + (1-a)*c.rand.NormFloat64()
+
+		signal[i] *= math.Hypot(steady+scattered*gain*re, scattered*gain*im)
+	}
+}
+
+// interfere Add another signal, steady or keyed.
+func (c *Channel) interfere(signal []float64, interferer Interferer, peak float64) {
+	keying := interferer.Keying
+	if keying == nil {
+		duration := time.Duration(float64(len(signal)) / float64(c.config.SampleRate) * float64(time.Second))
+		keying = []decode.Detection{{State: true, Duration: duration}}
+	}
+
+	synth := NewSynthesizer(Config{
+		SampleRate: c.config.SampleRate,
+		Frequency:  interferer.Frequency,
+		Amplitude:  interferer.Level * peak / math.MaxInt16,
+	})
+	rendered := synth.Render(keying)
+	for i := range min(len(signal), len(rendered)) {
+		signal[i] += float64(rendered[i])
+	}
+}
+
+// crash Add static crashes, bursts of noise with an exponential decay, arriving at random.
+func (c *Channel) crash(signal []float64, peak float64) {
+	sampleRate := float64(c.config.SampleRate)
+	decay := c.config.ImpulseDecay.Seconds() * sampleRate
+
+	for i := c.nextImpulse(0); i < len(signal); i = c.nextImpulse(i) {
+		level := c.config.ImpulseLevel * peak
+		for j := i; j < len(signal) && j-i < int(5*decay); j++ {
+			signal[j] += level * math.Exp(-float64(j-i)/decay) * c.rand.NormFloat64()
+		}
+	}
+}
+
+// nextImpulse Sample at which the next crash comes, with exponentially distributed waits between them.
+func (c *Channel) nextImpulse(from int) int {
+	return from + 1 + int(c.rand.ExpFloat64()/c.config.ImpulseRate*float64(c.config.SampleRate))
+}
+
+// noise Add white gaussian noise for the configured SNR.
+func (c *Channel) noise(signal []float64, peak float64) {
+	signalPower := peak * peak / 2
+	noisePower := signalPower / math.Pow(10, c.config.SNR/10)
+
+	// The noise is spread over the whole band, only part of it falls in the reference bandwidth.
+	sigma := math.Sqrt(noisePower * float64(c.config.SampleRate) / 2 / snrBandwidth)
+	for i := range signal {
+		signal[i] += sigma * c.rand.NormFloat64()
+	}
+}
@@ -0,0 +1,137 @@
+package synth
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/decode"
+)
+
+// tone A steady tone at half scale, long enough for the statistics to settle.
+func tone(d time.Duration) []int16 {
+	return NewSynthesizer(Config{SampleRate: 8000, Frequency: 700}).Render([]decode.Detection{{State: true, Duration: d}})
+}
+
+func power(samples []int16) float64 {
+	total := 0.0
+	for _, s := range samples {
+		total += float64(s) * float64(s)
+	}
+	return total / float64(len(samples))
+}
+
+func Test_ChannelNoise(t *testing.T) {
+	silence := make([]int16, 80000)
+	// The level is taken from the loudest sample, a single one is enough. Kept low so the noise doesn't clip.
+	silence[0] = 1000
+
+	for _, snr := range []float64{-10, 0, 10} {
+		received := NewChannel(ChannelConfig{SampleRate: 8000, SNR: snr, Noise: true}).Apply(silence)
+
+		// Noise over the whole band is 8000/2/2500 times the noise in the reference bandwidth.
+		signalPower := 1000.0 * 1000 / 2
+		measured := 10 * math.Log10(signalPower/(power(received[1:])*2500/4000))
+		if math.Abs(measured-snr) > 0.2 {
+			t.Errorf("expecting an SNR of %.0f dB, got %.2f dB", snr, measured)
+		}
+	}
+}
+
+// faded Fraction of the received samples, in blocks of 10ms, more than 10 dB below the clean signal.
+func faded(received, clean []int16) float64 {
+	blocks, faded := 0, 0
+	for start := 0; start+80 <= len(received); start += 80 {
+		blocks++
+		if power(received[start:start+80]) < power(clean[start:start+80])/10 {
+			faded++
+		}
+	}
+	return float64(faded) / float64(blocks)
+}
+
+func Test_ChannelFading(t *testing.T) {
+	clean := tone(60 * time.Second)
+
+	rayleigh := NewChannel(ChannelConfig{SampleRate: 8000, FadingRate: 1}).Apply(clean)
+	rician := NewChannel(ChannelConfig{SampleRate: 8000, FadingRate: 1, FadingSteady: 0.9}).Apply(clean)
+
+	// Power is kept on average, but goes up and down along the way.
+	for _, received := range [][]int16{rayleigh, rician} {
+		if ratio := power(received) / power(clean); math.Abs(ratio-1) > 0.3 {
+			t.Errorf("expecting the average power to be kept, got a ratio of %.2f", ratio)
+		}
+	}
+
+	// A Rayleigh channel is 10 dB down about a tenth of the time, a strong steady path makes that rare.
+	if fraction := faded(rayleigh, clean); fraction < 0.05 || fraction > 0.15 {
+		t.Errorf("expecting Rayleigh fades 10%% of the time, got %.1f%%", 100*fraction)
+	}
+	if fraction := faded(rician, clean); fraction > 0.02 {
+		t.Errorf("expecting a steady path to keep out of deep fades, got %.1f%% of the time", 100*fraction)
+	}
+}
+
+func Test_ChannelReproducible(t *testing.T) {
+	clean := tone(time.Second)
+	cfg := ChannelConfig{
+		SampleRate:   8000,
+		SNR:          6,
+		Noise:        true,
+		FadingRate:   0.5,
+		Interferers:  []Interferer{{Frequency: 900, Level: 0.5}},
+		ImpulseRate:  5,
+		ImpulseLevel: 2,
+		Seed:         42,
+	}
+
+	if !slices.Equal(NewChannel(cfg).Apply(clean), NewChannel(cfg).Apply(clean)) {
+		t.Error("expecting the same audio for the same seed")
+	}
+
+	other := cfg
+	other.Seed = 43
+	if slices.Equal(NewChannel(cfg).Apply(clean), NewChannel(other).Apply(clean)) {
+		t.Error("expecting different audio for another seed")
+	}
+}
+
+func Test_ChannelInterference(t *testing.T) {
+	clean := tone(time.Second)
+	keying := []decode.Detection{
+		{State: false, Duration: 500 * time.Millisecond},
+		{State: true, Duration: 500 * time.Millisecond},
+	}
+
+	received := NewChannel(ChannelConfig{
+		SampleRate:  8000,
+		Interferers: []Interferer{{Frequency: 1000, Level: 1, Keying: keying}},
+	}).Apply(clean)
+
+	// Keyed half way through, the tones add up in the second half only.
+	if ratio := power(received[:4000]) / power(clean[:4000]); math.Abs(ratio-1) > 0.01 {
+		t.Errorf("expecting the first half untouched, got a power ratio of %.2f", ratio)
+	}
+	if ratio := power(received[4400:]) / power(clean[4400:]); math.Abs(ratio-2) > 0.1 {
+		t.Errorf("expecting twice the power in the second half, got a ratio of %.2f", ratio)
+	}
+}
+
+func Test_ChannelImpulses(t *testing.T) {
+	silence := make([]int16, 80000)
+	silence[0] = 1000
+
+	received := NewChannel(ChannelConfig{SampleRate: 8000, ImpulseRate: 10, ImpulseLevel: 5}).Apply(silence)
+
+	// Around a hundred crashes over ten seconds, each one a few milliseconds long.
+	crashes := 0
+	for i := 1; i < len(received); i++ {
+		if math.Abs(float64(received[i])) > 1000 && math.Abs(float64(received[i-1])) < 50 {
+			crashes++
+		}
+	}
+	if crashes < 50 || crashes > 150 {
+		t.Errorf("expecting around 100 crashes, got %d", crashes)
+	}
+}
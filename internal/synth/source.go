@@ -0,0 +1,80 @@
+package synth
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/audio"
+)
+
+const defaultPeriod = 10 * time.Millisecond
+
+// SourceConfig How the samples are handed over.
+type SourceConfig struct {
+	SampleRate int
+	// Period Audio handed over per callback, defaults to 10ms.
+	Period time.Duration
+	// Realtime Pace the callbacks as a sound card would, one period at a time. Otherwise they come as fast as they are
+	// consumed.
+	Realtime bool
+}
+
+// Source In-memory audio source, playing samples in place of a sound card. Blocks are signed 16-bit mono.
+type Source struct {
+	config  SourceConfig
+	samples []int16
+}
+
+// NewSource Create a source playing the samples.
+func NewSource(samples []int16, cfg SourceConfig) *Source {
+	if cfg.Period <= 0 {
+		cfg.Period = defaultPeriod
+	}
+
+	return &Source{
+		config:  cfg,
+		samples: samples,
+	}
+}
+
+// Run Hand the samples to the handler, one period at a time, until they run out or the context is done. The last
+// period is padded with silence.
+func (s *Source) Run(ctx context.Context, handler audio.Handler) error {
+	// At least a frame at a time, however short the period.
+	frames := max(int(s.config.Period.Seconds()*float64(s.config.SampleRate)), 1)
+	block := make([]byte, 2*frames)
+
+	var tick <-chan time.Time
+	if s.config.Realtime {
+		ticker := time.NewTicker(s.config.Period)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for start := 0; start < len(s.samples); start += frames {
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		clear(block)
+		for i, sample := range s.samples[start:min(start+frames, len(s.samples))] {
+			binary.LittleEndian.PutUint16(block[2*i:], uint16(sample))
+		}
+		handler(audio.Block{
+			Data:       block,
+			Format:     audio.FormatS16,
+			Channels:   1,
+			SampleRate: s.config.SampleRate,
+			Time:       time.Duration(float64(start) / float64(s.config.SampleRate) * float64(time.Second)),
+		})
+	}
+
+	return nil
+}
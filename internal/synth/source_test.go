@@ -0,0 +1,81 @@
+package synth
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/audio"
+)
+
+func Test_Source(t *testing.T) {
+	samples := make([]int16, 250)
+	for i := range samples {
+		samples[i] = int16(i + 1)
+	}
+	source := NewSource(samples, SourceConfig{SampleRate: 8000, Period: 10 * time.Millisecond})
+
+	received := []int16{}
+	calls := 0
+	err := source.Run(context.Background(), func(block audio.Block) {
+		if block.Time != time.Duration(calls)*10*time.Millisecond || block.SampleRate != 8000 {
+			t.Errorf("expecting period %d at %v and 8000Hz, got %v and %dHz", calls, time.Duration(calls)*10*time.Millisecond,
+				block.Time, block.SampleRate)
+		}
+		calls++
+		for i := range block.Frames() {
+			received = append(received, int16(binary.LittleEndian.Uint16(block.Data[2*i:])))
+		}
+	})
+	if err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+
+	// Periods of 80 frames, the last one padded.
+	if calls != 4 || len(received) != 320 {
+		t.Fatalf("expecting 4 periods of 80 frames, got %d calls and %d frames", calls, len(received))
+	}
+	for i, s := range received {
+		exp := int16(0)
+		if i < len(samples) {
+			exp = samples[i]
+		}
+		if s != exp {
+			t.Fatalf("expecting %d at %d, got %d", exp, i, s)
+		}
+	}
+}
+
+func Test_SourceShortPeriod(t *testing.T) {
+	// Shorter than a sample.
+	source := NewSource(make([]int16, 10), SourceConfig{SampleRate: 8000, Period: time.Microsecond})
+
+	calls := 0
+	if err := source.Run(context.Background(), func(block audio.Block) {
+		if block.Frames() != 1 {
+			t.Errorf("expecting a frame per period, got %d", block.Frames())
+		}
+		calls++
+	}); err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+	if calls != 10 {
+		t.Errorf("expecting 10 periods, got %d", calls)
+	}
+}
+
+func Test_SourceCancelled(t *testing.T) {
+	source := NewSource(make([]int16, 8000), SourceConfig{SampleRate: 8000, Realtime: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	if err := source.Run(ctx, func(audio.Block) { calls++ }); err != context.DeadlineExceeded {
+		t.Errorf("expecting the deadline to be exceeded, got %v", err)
+	}
+	if calls == 0 || calls > 5 {
+		t.Errorf("expecting a few periods played in real time, got %d", calls)
+	}
+}
@@ -0,0 +1,121 @@
+package synth
+
+import (
+	"math"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/decode"
+)
+
+const (
+	defaultAmplitude = 0.5
+	defaultRiseTime  = 5 * time.Millisecond
+	defaultChirpTime = 10 * time.Millisecond
+)
+
+// Config Sound of the generated CW.
+type Config struct {
+	SampleRate int
+	// Frequency Tone frequency, in Hz.
+	Frequency float64
+
+	// Amplitude Peak level of the tone, from 0 to 1 (full scale). Defaults to 0.5.
+	Amplitude float64
+
+	// RiseTime Length of the raised-cosine ramps shaping the start and end of each mark, defaults to 5ms. Hard keying
+	// spreads clicks all over the band, a few milliseconds of ramp keeps the signal clean.
+	RiseTime time.Duration
+
+	// Drift Rate at which the tone frequency wanders off, in Hz per second of transmission.
+	Drift float64
+	// Chirp Frequency offset (Hz) at key down, fading away over ChirpTime (10ms by default) as a poorly regulated
+	// transmitter settles.
+	Chirp     float64
+	ChirpTime time.Duration
+}
+
+// Synthesizer Renders detection sequences to 16-bit PCM. Consecutive calls to Render carry on from where the previous
+// one stopped, so a transmission can be rendered piece by piece.
+type Synthesizer struct {
+	config Config
+
+	// Samples rendered so far and the phase of the tone, keeping it going from one call to the next.
+	position int
+	phase    float64
+	// Time rendered so far, so rounding to whole samples doesn't add up over a long transmission.
+	elapsed time.Duration
+}
+
+// NewSynthesizer Create a synthesizer.
+func NewSynthesizer(cfg Config) *Synthesizer {
+	if cfg.Amplitude <= 0 {
+		cfg.Amplitude = defaultAmplitude
+	}
+	if cfg.RiseTime <= 0 {
+		cfg.RiseTime = defaultRiseTime
+	}
+	if cfg.ChirpTime <= 0 {
+		cfg.ChirpTime = defaultChirpTime
+	}
+
+	return &Synthesizer{config: cfg}
+}
+
+// Render Turn the detections into samples: the tone for marks, silence for gaps.
+func (s *Synthesizer) Render(detections []decode.Detection) []int16 {
+	samples := []int16{}
+	for _, d := range detections {
+		s.elapsed += d.Duration
+		end := s.sampleAt(s.elapsed)
+		length := end - s.position
+
+		if d.State {
+			samples = append(samples, s.tone(length)...)
+		} else {
+			samples = append(samples, make([]int16, length)...)
+		}
+		s.position = end
+	}
+
+	return samples
+}
+
+// Silence Samples for the given duration of silence, keeping track of time like Render does.
+func (s *Synthesizer) Silence(d time.Duration) []int16 {
+	return s.Render([]decode.Detection{{State: false, Duration: d}})
+}
+
+// tone A keyed tone of the given length, ramping up and down with a raised-cosine envelope. Marks too short for the
+// full ramps get shorter ones.
+func (s *Synthesizer) tone(length int) []int16 {
+	rise := min(s.sampleAt(s.config.RiseTime), length/2)
+	sampleRate := float64(s.config.SampleRate)
+	chirpTime := s.config.ChirpTime.Seconds() * sampleRate
+
+	samples := make([]int16, length)
+	for i := range samples {
+		envelope := 1.0
+		if i < rise {
+			envelope = raisedCosine(float64(i) / float64(rise))
+		} else if length-1-i < rise {
+			envelope = raisedCosine(float64(length-1-i) / float64(rise))
+		}
+
+		frequency := s.config.Frequency + s.config.Drift*float64(s.position+i)/sampleRate +
+			s.config.Chirp*math.Exp(-float64(i)/chirpTime)
+		s.phase = math.Mod(s.phase+2*math.Pi*frequency/sampleRate, 2*math.Pi)
+
+		samples[i] = int16(math.Round(envelope * s.config.Amplitude * math.Sin(s.phase) * math.MaxInt16))
+	}
+
+	return samples
+}
+
+// raisedCosine Envelope going from 0 to 1 as x goes from 0 to 1.
+func raisedCosine(x float64) float64 {
+	return 0.5 - 0.5*math.Cos(math.Pi*x)
+}
+
+func (s *Synthesizer) sampleAt(d time.Duration) int {
+	return int(math.Round(d.Seconds() * float64(s.config.SampleRate)))
+}
@@ -0,0 +1,90 @@
+package synth
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rebay1982/gmorse/internal/decode"
+)
+
+func Test_Render(t *testing.T) {
+	synth := NewSynthesizer(Config{SampleRate: 8000, Frequency: 700, Amplitude: 0.5})
+
+	detections := []decode.Detection{
+		{State: true, Duration: 60 * time.Millisecond},
+		{State: false, Duration: 60 * time.Millisecond},
+	}
+	samples := synth.Render(detections)
+
+	if len(samples) != 960 {
+		t.Fatalf("expecting 960 samples, got %d", len(samples))
+	}
+
+	// Keyed softly, at full level in the middle of the mark and silent in the gap.
+	if samples[0] != 0 || math.Abs(float64(samples[1])) > 100 {
+		t.Errorf("expecting the mark to ramp up, got %v", samples[:4])
+	}
+	peak := 0.0
+	for _, s := range samples[200:400] {
+		peak = max(peak, math.Abs(float64(s)))
+	}
+	if math.Abs(peak-0.5*math.MaxInt16) > 200 {
+		t.Errorf("expecting a peak around %d, got %.0f", math.MaxInt16/2, peak)
+	}
+	for i, s := range samples[480:] {
+		if s != 0 {
+			t.Fatalf("expecting silence in the gap, got %d at %d", s, 480+i)
+		}
+	}
+}
+
+func Test_RenderKeepsTime(t *testing.T) {
+	synth := NewSynthesizer(Config{SampleRate: 8000, Frequency: 700})
+
+	// 1/3 ms doesn't fall on a sample, rounding must not add up.
+	total := 0
+	for range 3000 {
+		total += len(synth.Render([]decode.Detection{{State: true, Duration: time.Millisecond / 3}}))
+	}
+
+	if total != 8000 {
+		t.Errorf("expecting 8000 samples for a second, got %d", total)
+	}
+}
+
+// zeroCrossingFrequency Frequency of a tone measured from its rising zero crossings.
+func zeroCrossingFrequency(samples []int16, sampleRate int) float64 {
+	first, last, crossings := -1, -1, 0
+	for i := 1; i < len(samples); i++ {
+		if samples[i-1] < 0 && samples[i] >= 0 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+			crossings++
+		}
+	}
+	return float64(crossings-1) * float64(sampleRate) / float64(last-first)
+}
+
+func Test_RenderDriftAndChirp(t *testing.T) {
+	mark := []decode.Detection{{State: true, Duration: 2 * time.Second}}
+
+	drifting := NewSynthesizer(Config{SampleRate: 8000, Frequency: 700, Drift: 10}).Render(mark)
+	if f := zeroCrossingFrequency(drifting[:1600], 8000); math.Abs(f-701) > 2 {
+		t.Errorf("expecting the tone around 701Hz at first, got %.1f", f)
+	}
+	if f := zeroCrossingFrequency(drifting[14400:], 8000); math.Abs(f-719) > 2 {
+		t.Errorf("expecting the tone around 719Hz at the end, got %.1f", f)
+	}
+
+	chirping := NewSynthesizer(Config{SampleRate: 8000, Frequency: 700, Chirp: 100, ChirpTime: 20 * time.Millisecond}).
+		Render(mark)
+	if f := zeroCrossingFrequency(chirping[:160], 8000); f < 750 {
+		t.Errorf("expecting the tone well above 700Hz at key down, got %.1f", f)
+	}
+	if f := zeroCrossingFrequency(chirping[8000:], 8000); math.Abs(f-700) > 1 {
+		t.Errorf("expecting the tone to settle at 700Hz, got %.1f", f)
+	}
+}
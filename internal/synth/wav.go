@@ -0,0 +1,53 @@
+package synth
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const (
+	wavHeaderSize = 44
+	wavFormatPCM  = 1
+)
+
+// wavHeader Canonical RIFF/WAVE header for mono 16-bit PCM.
+type wavHeader struct {
+	ChunkID       [4]byte
+	ChunkSize     uint32
+	Format        [4]byte
+	Subchunk1ID   [4]byte
+	Subchunk1Size uint32
+	AudioFormat   uint16
+	NumChannels   uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+	Subchunk2ID   [4]byte
+	Subchunk2Size uint32
+}
+
+// WriteWAV Write the samples as a mono 16-bit PCM WAV file.
+func WriteWAV(w io.Writer, sampleRate int, samples []int16) error {
+	dataSize := uint32(2 * len(samples))
+	header := wavHeader{
+		ChunkID:       [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     wavHeaderSize - 8 + dataSize,
+		Format:        [4]byte{'W', 'A', 'V', 'E'},
+		Subchunk1ID:   [4]byte{'f', 'm', 't', ' '},
+		Subchunk1Size: 16,
+		AudioFormat:   wavFormatPCM,
+		NumChannels:   1,
+		SampleRate:    uint32(sampleRate),
+		ByteRate:      uint32(2 * sampleRate),
+		BlockAlign:    2,
+		BitsPerSample: 16,
+		Subchunk2ID:   [4]byte{'d', 'a', 't', 'a'},
+		Subchunk2Size: dataSize,
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, samples)
+}
@@ -0,0 +1,28 @@
+package synth
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func Test_WriteWAV(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := WriteWAV(&buf, 8000, []int16{0, 1000, -1000}); err != nil {
+		t.Fatalf("expecting no error, got %v", err)
+	}
+
+	data := buf.Bytes()
+	if len(data) != wavHeaderSize+6 {
+		t.Fatalf("expecting %d bytes, got %d", wavHeaderSize+6, len(data))
+	}
+	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" || string(data[36:40]) != "data" {
+		t.Errorf("expecting a RIFF/WAVE header, got %q", data[:wavHeaderSize])
+	}
+	if rate := binary.LittleEndian.Uint32(data[24:28]); rate != 8000 {
+		t.Errorf("expecting a sample rate of 8000, got %d", rate)
+	}
+	if sample := int16(binary.LittleEndian.Uint16(data[wavHeaderSize+4:])); sample != -1000 {
+		t.Errorf("expecting the last sample to be -1000, got %d", sample)
+	}
+}